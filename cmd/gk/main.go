@@ -1,14 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 
 	"github.com/sergeizaitcev/gophkeeper/internal/gophkeeper"
 )
 
 func main() {
 	if err := gophkeeper.Command.Execute(); err != nil {
+		// Код завершения команды, запущенной gk exec, передаётся как есть.
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() > 0 {
+			os.Exit(exit.ExitCode())
+		}
 		fmt.Printf("error: %s\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// DefaultTimeout определяет время бездействия по умолчанию, по истечении
+// которого агент забывает ключи.
+const DefaultTimeout = 15 * time.Minute
+
+// Операции агента.
+const (
+	OpGet  = "get"  // Получение ключа.
+	OpPut  = "put"  // Сохранение ключа.
+	OpLock = "lock" // Удаление всех ключей.
+)
+
+// ErrNotFound возвращается, когда ключ отсутствует в агенте.
+var ErrNotFound = errors.New("key not found")
+
+// Request определяет запрос к агенту.
+type Request struct {
+	Op  string `json:"op"`            // Операция.
+	ID  string `json:"id,omitempty"`  // Идентификатор ключа.
+	Key []byte `json:"key,omitempty"` // Ключ.
+}
+
+// Response определяет ответ агента.
+type Response struct {
+	Key   []byte `json:"key,omitempty"`   // Ключ.
+	Error string `json:"error,omitempty"` // Ошибка.
+}
+
+// Agent определяет агент, который хранит ключи шифрования в защищённой
+// памяти и забывает их по истечении времени бездействия.
+type Agent struct {
+	mu      sync.Mutex
+	keys    map[string]*secmem.Buffer
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// New возвращает новый экземпляр Agent. Если timeout <= 0, то ключи хранятся
+// до явной блокировки.
+func New(timeout time.Duration) *Agent {
+	return &Agent{
+		keys:    make(map[string]*secmem.Buffer),
+		timeout: timeout,
+	}
+}
+
+// Listen создает Unix-сокет агента по пути path, доступный только текущему
+// пользователю. Если по пути path уже отвечает другой агент, то возвращается
+// ошибка.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, errors.New("agent is already running")
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// Serve принимает подключения к агенту до закрытия ln.
+func (a *Agent) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go a.handle(conn)
+	}
+}
+
+// Lock удаляет все ключи из памяти агента.
+func (a *Agent) Lock() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lock()
+}
+
+func (a *Agent) lock() {
+	for id, key := range a.keys {
+		key.Destroy()
+		delete(a.keys, id)
+	}
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+// touch продлевает время хранения ключей.
+func (a *Agent) touch() {
+	if a.timeout <= 0 {
+		return
+	}
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.timeout, a.Lock)
+		return
+	}
+	a.timer.Reset(a.timeout)
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if err := checkPeer(conn); err != nil {
+		_ = json.NewEncoder(conn).Encode(Response{Error: err.Error()})
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	resp := a.do(req)
+	_ = json.NewEncoder(conn).Encode(resp)
+	secmem.Wipe(resp.Key)
+}
+
+func (a *Agent) do(req Request) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch req.Op {
+	case OpGet:
+		key, ok := a.keys[req.ID]
+		if !ok {
+			return Response{Error: ErrNotFound.Error()}
+		}
+		a.touch()
+		// Копия ключа не зависит от его удаления до отправки ответа.
+		return Response{Key: bytes.Clone(key.Bytes())}
+	case OpPut:
+		if len(req.Key) == 0 {
+			return Response{Error: "key must not be empty"}
+		}
+		a.keys[req.ID].Destroy()
+		a.keys[req.ID] = secmem.Copy(req.Key)
+		secmem.Wipe(req.Key)
+		a.touch()
+		return Response{}
+	case OpLock:
+		a.lock()
+		return Response{}
+	default:
+		return Response{Error: fmt.Sprintf("unknown operation: %q", req.Op)}
+	}
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testAgent(t *testing.T, timeout time.Duration) *Client {
+	path := filepath.Join(t.TempDir(), "agent")
+
+	ln, err := Listen(path)
+	require.NoError(t, err)
+
+	a := New(timeout)
+
+	go func() { _ = a.Serve(ln) }()
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+		a.Lock()
+	})
+
+	_, err = Listen(path)
+	require.Error(t, err)
+
+	return NewClient(path)
+}
+
+func TestAgent(t *testing.T) {
+	c := testAgent(t, 0)
+
+	_, err := c.Get("id")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, c.Put("id", []byte("key")))
+
+	key, err := c.Get("id")
+	require.NoError(t, err)
+	require.Equal(t, []byte("key"), key)
+
+	require.NoError(t, c.Lock())
+
+	_, err = c.Get("id")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAgent_Timeout(t *testing.T) {
+	c := testAgent(t, 50*time.Millisecond)
+
+	require.NoError(t, c.Put("id", []byte("key")))
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err := c.Get("id")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_NotRunning(t *testing.T) {
+	c := NewClient(filepath.Join(t.TempDir(), "agent"))
+
+	_, err := c.Get("id")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+)
+
+// dialTimeout определяет время ожидания подключения к агенту.
+const dialTimeout = time.Second
+
+// Client определяет клиент агента.
+type Client struct {
+	path string
+}
+
+// NewClient возвращает новый экземпляр Client для агента, ожидающего
+// подключения по пути path.
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// Get возвращает ключ по ID. Если ключ отсутствует, то возвращается
+// ErrNotFound.
+func (c *Client) Get(id string) ([]byte, error) {
+	res, err := c.do(Request{Op: OpGet, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	return res.Key, nil
+}
+
+// Put сохраняет ключ в агенте.
+func (c *Client) Put(id string, key []byte) error {
+	_, err := c.do(Request{Op: OpPut, ID: id, Key: key})
+	return err
+}
+
+// Lock удаляет все ключи из агента.
+func (c *Client) Lock() error {
+	_, err := c.do(Request{Op: OpLock})
+	return err
+}
+
+func (c *Client) do(req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	if err = json.NewEncoder(conn).Encode(&req); err != nil {
+		return Response{}, err
+	}
+
+	var res Response
+	if err = json.NewDecoder(conn).Decode(&res); err != nil {
+		return Response{}, err
+	}
+
+	switch res.Error {
+	case "":
+		return res, nil
+	case ErrNotFound.Error():
+		return Response{}, ErrNotFound
+	default:
+		return Response{}, errors.New(res.Error)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package agent
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkPeer проверяет, что подключение к агенту установлено процессом
+// текущего пользователя.
+func checkPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("connection is not a unix socket")
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+
+	if int(cred.Uid) != os.Getuid() {
+		return errors.New("permission denied")
+	}
+
+	return nil
+}
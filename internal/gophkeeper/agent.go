@@ -0,0 +1,69 @@
+package gophkeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/agent"
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+)
+
+var (
+	flagAgentForeground bool          // Запуск агента в текущем процессе.
+	flagAgentTimeout    time.Duration // Время бездействия агента.
+)
+
+// Agent запускает агент ключей шифрования в фоновом режиме.
+func Agent([]string) error {
+	path, err := vault.AgentPath()
+	if err != nil {
+		return err
+	}
+
+	if !flagAgentForeground {
+		pid, err := startAgent(path, flagAgentTimeout)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("the agent has been started, pid %d\n", pid)
+		return nil
+	}
+
+	ln, err := agent.Listen(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	a := agent.New(flagAgentTimeout)
+	defer a.Lock()
+
+	return a.Serve(ln)
+}
+
+// Lock удаляет ключи шифрования из агента.
+func Lock([]string) error {
+	path, err := vault.AgentPath()
+	if err != nil {
+		return err
+	}
+
+	if err = agent.NewClient(path).Lock(); err != nil {
+		return errors.New("agent is not running")
+	}
+
+	fmt.Println("the agent has been locked")
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package gophkeeper
+
+import (
+	"errors"
+	"time"
+)
+
+// startAgent не поддерживается на текущей платформе.
+func startAgent(string, time.Duration) (int, error) {
+	return 0, errors.New("background agent is not supported on this platform, use -f")
+}
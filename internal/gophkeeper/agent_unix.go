@@ -0,0 +1,49 @@
+//go:build unix
+
+package gophkeeper
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// startAgent запускает агент в отдельном сеансе и дожидается его готовности.
+func startAgent(path string, timeout time.Duration) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(exe, "agent", "-f", "-t", timeout.String())
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err = cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	exited := make(chan error, 1)
+
+	go func() { exited <- cmd.Wait() }()
+
+	for i := 0; i < 50; i++ {
+		select {
+		case <-exited:
+			return 0, errors.New("agent has exited unexpectedly; is it already running?")
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			_ = cmd.Process.Release()
+			return pid, nil
+		}
+	}
+
+	_ = cmd.Process.Kill()
+
+	return 0, errors.New("agent has not started in time")
+}
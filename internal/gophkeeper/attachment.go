@@ -0,0 +1,76 @@
+package gophkeeper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rodaine/table"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+)
+
+var (
+	flagAttachmentName string // Имя вложения.
+	flagAttachment     string // Выводимое вложение.
+)
+
+// Attach добавляет к данным вложение из файла.
+func Attach(args []string) error {
+	if len(args) < 2 {
+		return errArgsTooSmall
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := flagAttachmentName
+	if name == "" {
+		name = filepath.Base(args[1])
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.Attach(args[0], name, f); err != nil {
+		return err
+	}
+
+	fmt.Println("the attachment has been successfully added")
+
+	return nil
+}
+
+// Attachments выводит список вложений данных.
+func Attachments(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	files, err := v.Attachments(args[0])
+	if err != nil {
+		return err
+	}
+
+	tb := table.New("ID", "NAME", "LAST UPDATE")
+
+	for _, file := range files {
+		tb.AddRow(file.ID, file.Description, file.LastUpdate.Local().Format("2006-01-02 15:04"))
+	}
+
+	tb.Print()
+
+	return nil
+}
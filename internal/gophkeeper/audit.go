@@ -0,0 +1,59 @@
+package gophkeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rodaine/table"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+)
+
+var (
+	flagAuditJSON       bool    // Вывод в формате JSON.
+	flagAuditDays       int     // Срок устаревания данных в днях.
+	flagAuditMinEntropy float64 // Минимальная энтропия пароля в битах.
+)
+
+// Audit проверяет данные хранилища и выводит отчёт о проблемах.
+func Audit([]string) error {
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	report, err := v.Audit(vault.AuditOptions{
+		Now:        time.Now().UTC(),
+		MaxAge:     time.Duration(flagAuditDays) * 24 * time.Hour,
+		MinEntropy: flagAuditMinEntropy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if flagAuditJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	if len(report.Findings) == 0 {
+		fmt.Printf("%d items checked, no issues found\n", report.Items)
+		return nil
+	}
+
+	tb := table.New("ID", "TYPE", "DESCRIPTION", "ISSUE", "DETAIL")
+
+	for _, f := range report.Findings {
+		tb.AddRow(f.ID, f.Type, f.Description, f.Issue, f.Detail)
+	}
+
+	tb.Print()
+
+	fmt.Printf("\n%d items checked, %d issues found\n", report.Items, len(report.Findings))
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package gophkeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rodaine/table"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/pwned"
+)
+
+var flagBreachJSON bool // Вывод в формате JSON.
+
+// BreachIndex строит компактный индекс из текстовой базы Pwned Passwords.
+func BreachIndex(args []string) error {
+	if len(args) < 2 {
+		return errArgsTooSmall
+	}
+
+	src, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	n, err := pwned.BuildIndex(dst, src)
+	if err == nil {
+		err = dst.Close()
+	} else {
+		_ = dst.Close()
+	}
+	if err != nil {
+		_ = os.Remove(args[1])
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	fmt.Printf("%d hashes indexed\n", n)
+
+	return nil
+}
+
+// BreachCheck ищет пароли учётных записей в локальной базе Pwned Passwords.
+func BreachCheck(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	db, err := pwned.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	findings, err := v.CheckBreaches(db)
+	if err != nil {
+		return err
+	}
+
+	if flagBreachJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("no breached passwords found")
+		return nil
+	}
+
+	tb := table.New("ID", "TYPE", "DESCRIPTION", "DETAIL")
+
+	for _, f := range findings {
+		tb.AddRow(f.ID, f.Type, f.Description, f.Detail)
+	}
+
+	tb.Print()
+
+	fmt.Printf("\n%d breached passwords found\n", len(findings))
+
+	return nil
+}
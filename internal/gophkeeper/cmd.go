@@ -3,20 +3,44 @@ package gophkeeper
 import (
 	"errors"
 	"flag"
+	"time"
 
+	"github.com/sergeizaitcev/gophkeeper/internal/agent"
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
 	"github.com/sergeizaitcev/gophkeeper/pkg/cli"
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
 	"github.com/sergeizaitcev/gophkeeper/version"
 )
 
 // errArgsTooSmall возвращается, когда передано слишком мало аргументов.
 var errArgsTooSmall = errors.New("there are too few arguments")
 
+// prepare подготавливает процесс к работе с секретами: запрещает дампы его
+// памяти и устанавливает источник мастер-пароля.
+func prepare() error {
+	if err := secmem.DisableCoreDumps(); err != nil {
+		return err
+	}
+	return setPasswordSource()
+}
+
 // Command определяет консольное приложение gophkeeper.
 var Command = &cli.Command{
 	Name:        "gk",
 	Description: "gophkeeper client",
 	Version:     version.Version,
+	Flags:       passwordFlags,
+	Prepare:     prepare,
 	Subcommands: []cli.Commander{
+		&cli.Subcommand{
+			Name:        "init",
+			Description: "initializing the vault on this device",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&flagKeyFile, "keyfile", "", "path to the key file, created if it does not exist")
+			},
+			Execute: Init,
+		},
 		&cli.CommandGroup{
 			Name:        "remote",
 			Description: "remote server settings",
@@ -48,9 +72,13 @@ var Command = &cli.Command{
 			Subcommands: []cli.Commander{
 				&cli.Subcommand{
 					Name:        "card",
-					Description: "adding a bank card number to the vault",
+					Description: "adding a bank card to the vault",
 					Flags: func(fs *flag.FlagSet) {
 						fs.StringVar(&flagDescription, "d", "", "description of the data")
+						fs.StringVar(&flagCardHolder, "holder", "", "name of the card holder")
+						fs.StringVar(&flagCardExpiry, "expiry", "", "expiry date of the card as MM/YY")
+						fs.StringVar(&flagCardCVV, "cvv", "", "verification code of the card")
+						fs.Func("recipient", "public key or path to the public key of a recipient, may be repeated", addRecipient)
 					},
 					Execute: AddBankCard,
 				},
@@ -59,6 +87,15 @@ var Command = &cli.Command{
 					Description: "adding a username-password to the vault",
 					Flags: func(fs *flag.FlagSet) {
 						fs.StringVar(&flagDescription, "d", "", "description of the data")
+						fs.Func("url", "website address, may be repeated", addLoginURL)
+						fs.StringVar(&flagLoginNotes, "notes", "", "notes on the login")
+						fs.Func("text", "custom text field as name=value, may be repeated", addLoginField(vault.FieldText))
+						fs.Func("hidden", "custom hidden field as name=value, may be repeated", addLoginField(vault.FieldHidden))
+						fs.Func("link", "custom url field as name=value, may be repeated", addLoginField(vault.FieldURL))
+						fs.Func("email", "custom email field as name=value, may be repeated", addLoginField(vault.FieldEmail))
+						fs.BoolVar(&flagGenerate, "generate", false, "generate the password instead of passing it")
+						genFlags(fs)
+						fs.Func("recipient", "public key or path to the public key of a recipient, may be repeated", addRecipient)
 					},
 					Execute: AddUsernamePassword,
 				},
@@ -67,10 +104,105 @@ var Command = &cli.Command{
 					Description: "adding a file to the vault",
 					Flags: func(fs *flag.FlagSet) {
 						fs.StringVar(&flagDescription, "d", "", "description of the data")
+						fs.Func("recipient", "public key or path to the public key of a recipient, may be repeated", addRecipient)
 					},
 					Execute: AddFile,
 				},
+				&cli.Subcommand{
+					Name:        "note",
+					Description: "adding a text note typed in $EDITOR to the vault",
+					Flags: func(fs *flag.FlagSet) {
+						fs.StringVar(&flagDescription, "d", "", "description of the data")
+						fs.Func("recipient", "public key or path to the public key of a recipient, may be repeated", addRecipient)
+					},
+					Execute: AddNote,
+				},
+				&cli.Subcommand{
+					Name:        "otp",
+					Description: "adding a TOTP or HOTP key from an otpauth:// uri or a base32 secret to the vault",
+					Flags: func(fs *flag.FlagSet) {
+						fs.StringVar(&flagDescription, "d", "", "description of the data")
+						fs.BoolVar(&flagOTPHOTP, "hotp", false, "counter-based key instead of time-based, for a base32 secret")
+						fs.Uint64Var(&flagOTPCounter, "counter", 0, "initial hotp counter, for a base32 secret")
+						fs.IntVar(&flagOTPDigits, "digits", otp.DefaultDigits, "number of digits, for a base32 secret")
+						fs.IntVar(&flagOTPPeriod, "period", otp.DefaultPeriod, "totp period in seconds, for a base32 secret")
+						fs.StringVar(&flagOTPAlgorithm, "algorithm", otp.DefaultAlgorithm, "SHA1, SHA256 or SHA512, for a base32 secret")
+						fs.StringVar(&flagOTPIssuer, "issuer", "", "service that issued the key, for a base32 secret")
+						fs.StringVar(&flagOTPAccount, "account", "", "account name, for a base32 secret")
+						fs.Func("recipient", "public key or path to the public key of a recipient, may be repeated", addRecipient)
+					},
+					Execute: AddOTP,
+				},
+				&cli.Subcommand{
+					Name:        "env",
+					Description: "adding environment variables from a .env file or - for stdin to the vault",
+					Flags: func(fs *flag.FlagSet) {
+						fs.StringVar(&flagDescription, "d", "", "description of the data")
+						fs.Func("recipient", "public key or path to the public key of a recipient, may be repeated", addRecipient)
+					},
+					Execute: AddEnv,
+				},
+			},
+		},
+		&cli.Subcommand{
+			Name:        "attach",
+			Description: "attaching an encrypted file to data in the vault",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&flagAttachmentName, "name", "", "name of the attachment, the file name by default")
+			},
+			Execute: Attach,
+		},
+		&cli.Subcommand{
+			Name:        "attachments",
+			Description: "show a list of attachments of data",
+			Execute:     Attachments,
+		},
+		&cli.Subcommand{
+			Name:        "edit",
+			Description: "editing data in place, keeping its ID",
+			Flags: func(fs *flag.FlagSet) {
+				fs.Func("d", "new description of the data", optionalString(&flagEditDescription))
+				fs.Func("number", "new number of a card", optionalString(&flagEditNumber))
+				fs.Func("holder", "new name of the card holder", optionalString(&flagEditHolder))
+				fs.Func("expiry", "new expiry date of a card as MM/YY", optionalString(&flagEditExpiry))
+				fs.Func("cvv", "new verification code of a card", optionalString(&flagEditCVV))
+				fs.Func("username", "new username of a login", optionalString(&flagEditUsername))
+				fs.Func("password", "new password of a login, the previous one is kept in its history", optionalString(&flagEditPassword))
+				fs.BoolVar(&flagGenerate, "generate", false, "generate a new password of a login")
+				genFlags(fs)
+				fs.Func("url", "website address of a login replacing the previous ones, may be repeated", addLoginURL)
+				fs.Func("notes", "new notes on a login", optionalString(&flagEditNotes))
+				fs.Func("text", "custom text field of a login as name=value, may be repeated", addLoginField(vault.FieldText))
+				fs.Func("hidden", "custom hidden field of a login as name=value, may be repeated", addLoginField(vault.FieldHidden))
+				fs.Func("link", "custom url field of a login as name=value, may be repeated", addLoginField(vault.FieldURL))
+				fs.Func("email", "custom email field of a login as name=value, may be repeated", addLoginField(vault.FieldEmail))
+				fs.Func("rm-field", "name of a custom field of a login to remove, may be repeated", addRemoveField)
+				fs.Func("secret", "new base32 secret or otpauth:// uri of an otp key", optionalString(&flagEditSecret))
+				fs.Func("issuer", "new service that issued an otp key", optionalString(&flagEditIssuer))
+				fs.Func("account", "new account name of an otp key", optionalString(&flagEditAccount))
+				fs.Func("algorithm", "new algorithm of an otp key: SHA1, SHA256 or SHA512", optionalString(&flagEditAlgorithm))
+				fs.Func("digits", "new number of digits of an otp key", optionalString(&flagEditDigits))
+				fs.Func("period", "new totp period in seconds", optionalString(&flagEditPeriod))
+				fs.Func("counter", "new hotp counter", optionalString(&flagEditCounter))
+				fs.StringVar(&flagEditFile, "file", "", "path to new contents of a file, note or environment, - for stdin")
+			},
+			Execute: Edit,
+		},
+		&cli.Subcommand{
+			Name:        "log",
+			Description: "show the revisions of data",
+			Flags: func(fs *flag.FlagSet) {
+				fs.IntVar(&flagRevisionLimit, "limit", -1, "set the number of previous revisions kept on this device, 0 to disable")
 			},
+			Execute: Log,
+		},
+		&cli.Subcommand{
+			Name:        "restore",
+			Description: "restoring a previous revision of data",
+			Flags: func(fs *flag.FlagSet) {
+				fs.IntVar(&flagRevision, "rev", 0, "number of the revision to restore")
+			},
+			Execute: Restore,
 		},
 		&cli.Subcommand{
 			Name:        "rm",
@@ -87,13 +219,181 @@ var Command = &cli.Command{
 			Description: "show data in the vault",
 			Flags: func(fs *flag.FlagSet) {
 				fs.StringVar(&flagOutput, "o", "", "path to output")
+				fs.StringVar(&flagField, "field", "", "show only the field of a card, login or environment, e.g. number, password or a variable name")
+				fs.BoolVar(&flagMask, "mask", false, "mask card numbers, cvv, passwords, hidden fields and variables")
+				fs.StringVar(&flagAttachment, "attachment", "", "show the attachment with the name instead of data")
 			},
 			Execute: Show,
 		},
+		&cli.Subcommand{
+			Name:        "chpass",
+			Description: "changing the password of a login and keeping the previous one in its history",
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&flagGenerate, "generate", false, "generate the password instead of passing it")
+				genFlags(fs)
+			},
+			Execute: ChangeLoginPassword,
+		},
+		&cli.Subcommand{
+			Name:        "history",
+			Description: "show the previous passwords of a login",
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&flagMask, "mask", false, "mask the passwords")
+				fs.IntVar(&flagHistoryLimit, "limit", -1, "set the number of previous passwords kept on this device, 0 to disable")
+			},
+			Execute: History,
+		},
+		&cli.Subcommand{
+			Name:        "gen",
+			Description: "generating a password or a passphrase",
+			Flags:       genFlags,
+			Execute:     Gen,
+		},
+		&cli.Subcommand{
+			Name:        "otp",
+			Description: "show the current one-time password",
+			Execute:     OTP,
+		},
+		&cli.Subcommand{
+			Name:        "exec",
+			Description: "running a command with environment variables from the vault",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&flagExecEnv, "env", "", "comma-separated IDs of environment variables")
+			},
+			Execute: Exec,
+		},
 		&cli.Subcommand{
 			Name:        "ls",
 			Description: "show a list of all data in the vault",
 			Execute:     List,
 		},
+		&cli.Subcommand{
+			Name:        "audit",
+			Description: "checking the vault for weak, reused and stale passwords and expired cards",
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&flagAuditJSON, "json", false, "print the report as JSON")
+				fs.IntVar(&flagAuditDays, "days", int(vault.DefaultMaxAge/(24*time.Hour)), "days after which data is stale, 0 to disable")
+				fs.Float64Var(&flagAuditMinEntropy, "min-entropy", vault.DefaultMinEntropy, "minimum estimated password entropy in bits")
+			},
+			Execute: Audit,
+		},
+		&cli.CommandGroup{
+			Name:        "breach",
+			Description: "checking passwords against a local copy of Pwned Passwords",
+			Subcommands: []cli.Commander{
+				&cli.Subcommand{
+					Name:        "index",
+					Description: "building a compact index from a sorted SHA-1 or NTLM hash file",
+					Execute:     BreachIndex,
+				},
+				&cli.Subcommand{
+					Name:        "check",
+					Description: "searching stored passwords in a hash file or index",
+					Flags: func(fs *flag.FlagSet) {
+						fs.BoolVar(&flagBreachJSON, "json", false, "print the findings as JSON")
+					},
+					Execute: BreachCheck,
+				},
+			},
+		},
+		&cli.Subcommand{
+			Name:        "migrate",
+			Description: "re-encrypting the vault with the primary key",
+			Flags: func(fs *flag.FlagSet) {
+				fs.UintVar(&flagKDFTime, "t", 0, "argon2id iterations of a new primary key")
+				fs.UintVar(&flagKDFMemory, "m", 0, "argon2id memory in KiB of a new primary key")
+				fs.UintVar(&flagKDFThreads, "p", 0, "argon2id parallelism of a new primary key")
+			},
+			Execute: Migrate,
+		},
+		&cli.Subcommand{
+			Name:        "passwd",
+			Description: "changing the master password of the vault",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&flagKeyFile, "keyfile", "", "path to a new key file, created if it does not exist")
+				fs.BoolVar(&flagNoKeyFile, "no-keyfile", false, "stop using the key file")
+			},
+			Execute: Passwd,
+		},
+		&cli.CommandGroup{
+			Name:        "recovery",
+			Description: "recovering the vault without the master password",
+			Subcommands: []cli.Commander{
+				&cli.Subcommand{
+					Name:        "split",
+					Description: "splitting the vault keys into recovery shares",
+					Flags: func(fs *flag.FlagSet) {
+						fs.IntVar(&flagShares, "n", 5, "number of shares")
+						fs.IntVar(&flagThreshold, "k", 3, "number of shares required to recover")
+					},
+					Execute: RecoverySplit,
+				},
+				&cli.Subcommand{
+					Name:        "combine",
+					Description: "recovering the vault keys from shares and setting a new master password",
+					Flags: func(fs *flag.FlagSet) {
+						fs.StringVar(&flagKeyFile, "keyfile", "", "path to a new key file, created if it does not exist")
+						fs.BoolVar(&flagNoKeyFile, "no-keyfile", false, "stop using the key file")
+					},
+					Execute: RecoveryCombine,
+				},
+			},
+		},
+		&cli.Subcommand{
+			Name:        "rotate",
+			Description: "re-encrypting data with new item keys",
+			Execute:     Rotate,
+		},
+		&cli.CommandGroup{
+			Name:        "keys",
+			Description: "managing the recipient keys",
+			Subcommands: []cli.Commander{
+				&cli.Subcommand{
+					Name:        "generate",
+					Description: "generating a new recipient key",
+					Flags: func(fs *flag.FlagSet) {
+						fs.StringVar(&flagOutput, "o", "", "path to save the secret key")
+					},
+					Execute: KeysGenerate,
+				},
+				&cli.Subcommand{
+					Name:        "import",
+					Description: "importing a secret recipient key",
+					Execute:     KeysImport,
+				},
+				&cli.Subcommand{
+					Name:        "ls",
+					Description: "show a list of the recipient keys",
+					Execute:     KeysList,
+				},
+			},
+		},
+		&cli.Subcommand{
+			Name:        "export",
+			Description: "exporting encrypted data for recipients",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&flagOutput, "o", "", "path to output")
+			},
+			Execute: Export,
+		},
+		&cli.Subcommand{
+			Name:        "import",
+			Description: "importing encrypted data from an archive",
+			Execute:     Import,
+		},
+		&cli.Subcommand{
+			Name:        "agent",
+			Description: "starting an agent caching the vault keys",
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&flagAgentForeground, "f", false, "run the agent in the foreground")
+				fs.DurationVar(&flagAgentTimeout, "t", agent.DefaultTimeout, "idle timeout, 0 to disable")
+			},
+			Execute: Agent,
+		},
+		&cli.Subcommand{
+			Name:        "lock",
+			Description: "removing the vault keys from the agent",
+			Execute:     Lock,
+		},
 	},
 }
@@ -0,0 +1,432 @@
+package gophkeeper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/cliutil"
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// envName определяет имя временного файла переменных окружения в редакторе.
+const envName = ".env"
+
+// Флаги изменения данных. Значение nil означает, что поле не изменяется.
+var (
+	flagEditDescription  *string  // Новое описание данных.
+	flagEditNumber       *string  // Новый номер карты.
+	flagEditHolder       *string  // Новое имя держателя карты.
+	flagEditExpiry       *string  // Новый срок действия карты.
+	flagEditCVV          *string  // Новый CVV карты.
+	flagEditUsername     *string  // Новое имя пользователя.
+	flagEditPassword     *string  // Новый пароль.
+	flagEditNotes        *string  // Новые заметки к учётной записи.
+	flagEditSecret       *string  // Новый секрет или otpauth:// URI ключа OTP.
+	flagEditIssuer       *string  // Новый сервис, выдавший ключ OTP.
+	flagEditAccount      *string  // Новая учётная запись ключа OTP.
+	flagEditAlgorithm    *string  // Новый алгоритм HMAC ключа OTP.
+	flagEditDigits       *string  // Новое количество цифр пароля OTP.
+	flagEditPeriod       *string  // Новый период действия пароля TOTP.
+	flagEditCounter      *string  // Новое значение счётчика HOTP.
+	flagEditRemoveFields []string // Удаляемые дополнительные поля.
+	flagEditFile         string   // Файл с новым содержимым.
+)
+
+// optionalString возвращает функцию флага, сохраняющую значение в *p, чтобы
+// отличать пустое значение от незаданного.
+func optionalString(p **string) func(string) error {
+	return func(value string) error {
+		*p = &value
+		return nil
+	}
+}
+
+func addRemoveField(name string) error {
+	flagEditRemoveFields = append(flagEditRemoveFields, name)
+	return nil
+}
+
+// Edit изменяет данные в хранилище, сохраняя их ID: описание, поля карты и
+// учётной записи, секрет и параметры ключа одноразовых паролей или
+// содержимое файла, заметки и переменных окружения.
+// Заметки и переменные окружения без флагов открываются в редакторе.
+func Edit(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	file, err := v.Stat(args[0])
+	if err != nil {
+		return err
+	}
+
+	cardFlags := flagEditNumber != nil || flagEditHolder != nil || flagEditExpiry != nil || flagEditCVV != nil
+	loginFlags := flagEditUsername != nil || flagEditPassword != nil || flagGenerate || flagLoginURLs != nil ||
+		flagEditNotes != nil || flagLoginFields != nil || flagEditRemoveFields != nil
+	otpFlags := flagEditSecret != nil || flagEditIssuer != nil || flagEditAccount != nil || flagEditAlgorithm != nil ||
+		flagEditDigits != nil || flagEditPeriod != nil || flagEditCounter != nil
+	fileFlag := flagEditFile != ""
+
+	switch {
+	case cardFlags && file.Type != vault.TypeCard:
+		return fmt.Errorf("%s is %s, -number, -holder, -expiry and -cvv apply to %s only", file.ID, file.Type, vault.TypeCard)
+	case loginFlags && file.Type != vault.TypeLogpass:
+		return fmt.Errorf("%s is %s, login flags apply to %s only", file.ID, file.Type, vault.TypeLogpass)
+	case otpFlags && file.Type != vault.TypeTOTP:
+		return fmt.Errorf("%s is %s, otp flags apply to %s only", file.ID, file.Type, vault.TypeTOTP)
+	case fileFlag && file.Type != vault.TypeBinary && file.Type != vault.TypeNote && file.Type != vault.TypeEnv:
+		return fmt.Errorf("%s is %s, -file applies to %s, %s and %s only",
+			file.ID, file.Type, vault.TypeBinary, vault.TypeNote, vault.TypeEnv)
+	}
+
+	editor := !cardFlags && !loginFlags && !otpFlags && !fileFlag && flagEditDescription == nil
+
+	var changed bool
+	switch file.Type {
+	case vault.TypeCard:
+		changed, err = editBankCard(v, file.ID, cardFlags)
+	case vault.TypeLogpass:
+		changed, err = editLogin(v, file.ID, loginFlags)
+	case vault.TypeTOTP:
+		changed, err = editOTP(v, file.ID, otpFlags)
+	case vault.TypeNote:
+		changed, err = editNote(v, file.ID, editor)
+	case vault.TypeEnv:
+		changed, err = editEnv(v, file.ID, editor)
+	case vault.TypeBinary:
+		changed, err = editFile(v, file.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Описание изменяется вместе с содержимым, а без него — отдельно, чтобы
+	// каждая правка создавала одну ревизию.
+	if !changed && flagEditDescription != nil && *flagEditDescription != file.Description {
+		if err = v.Edit(file.ID, flagEditDescription, nil); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		if editor && file.Type != vault.TypeNote && file.Type != vault.TypeEnv {
+			return fmt.Errorf("%s is %s, pass the flags of the fields to change", file.ID, file.Type)
+		}
+		fmt.Println("the data has not been changed")
+		return nil
+	}
+
+	fmt.Println("the data has been successfully updated")
+
+	return nil
+}
+
+// editBankCard изменяет заданные флагами поля банковской карты.
+func editBankCard(v *vault.Vault, id string, set bool) (bool, error) {
+	if !set {
+		return false, nil
+	}
+
+	card, err := v.BankCard(id)
+	if err != nil {
+		return false, err
+	}
+	defer card.Wipe()
+
+	if flagEditNumber != nil {
+		secmem.Wipe(card.Number)
+		card.Number = vault.NewBankCard(*flagEditNumber).Number
+	}
+	if flagEditHolder != nil {
+		card.Holder = *flagEditHolder
+	}
+	if flagEditExpiry != nil {
+		card.Expiry = vault.Expiry{}
+		if *flagEditExpiry != "" {
+			if card.Expiry, err = vault.ParseExpiry(*flagEditExpiry); err != nil {
+				return false, err
+			}
+		}
+	}
+	if flagEditCVV != nil {
+		secmem.Wipe(card.CVV)
+		card.CVV = []byte(*flagEditCVV)
+	}
+
+	if err = card.Validate(); err != nil {
+		return false, err
+	}
+	if card.Expiry.Expired(time.Now()) {
+		fmt.Fprintf(os.Stderr, "warning: the card expired in %s\n", card.Expiry)
+	}
+
+	return true, v.UpdateBankCard(id, flagEditDescription, card)
+}
+
+// editLogin изменяет заданные флагами поля учётной записи. Прежний пароль
+// сохраняется в истории.
+func editLogin(v *vault.Vault, id string, set bool) (bool, error) {
+	if !set {
+		return false, nil
+	}
+	if flagGenerate && flagEditPassword != nil {
+		return false, errors.New("the password must not be passed with -generate")
+	}
+
+	up, err := v.Login(id)
+	if err != nil {
+		return false, err
+	}
+	defer up.Wipe()
+
+	if flagEditUsername != nil {
+		up.Username = *flagEditUsername
+	}
+	if flagGenerate {
+		password, err := generatePassword()
+		if err != nil {
+			return false, err
+		}
+		secmem.Wipe(up.Password)
+		up.Password = password
+	} else if flagEditPassword != nil {
+		secmem.Wipe(up.Password)
+		up.Password = []byte(*flagEditPassword)
+	}
+	if flagLoginURLs != nil {
+		// Пустой адрес удаляет все адреса учётной записи.
+		up.URLs = nil
+		for _, u := range flagLoginURLs {
+			if u != "" {
+				up.URLs = append(up.URLs, u)
+			}
+		}
+	}
+	if flagEditNotes != nil {
+		up.Notes = *flagEditNotes
+	}
+
+	for _, name := range flagEditRemoveFields {
+		i := fieldIndex(up.Fields, name)
+		if i < 0 {
+			return false, fmt.Errorf("%s has no field %q", id, name)
+		}
+		secmem.Wipe(up.Fields[i].Value)
+		up.Fields = append(up.Fields[:i], up.Fields[i+1:]...)
+	}
+	for _, cf := range flagLoginFields {
+		if i := fieldIndex(up.Fields, cf.Name); i >= 0 {
+			secmem.Wipe(up.Fields[i].Value)
+			up.Fields[i] = cf
+			continue
+		}
+		up.Fields = append(up.Fields, cf)
+	}
+
+	return true, v.UpdateLogin(id, flagEditDescription, up, time.Now())
+}
+
+// editOTP изменяет заданные флагами секрет и параметры ключа одноразовых
+// паролей. URI otpauth:// заменяет ключ целиком, а остальные флаги
+// применяются после него.
+func editOTP(v *vault.Vault, id string, set bool) (bool, error) {
+	if !set {
+		return false, nil
+	}
+
+	key, err := v.OTPKey(id)
+	if err != nil {
+		return false, err
+	}
+	defer func() { secmem.Wipe(key.Secret) }()
+
+	if flagEditSecret != nil {
+		var replaced otp.Key
+		if strings.HasPrefix(*flagEditSecret, "otpauth://") {
+			replaced, err = otp.Parse(*flagEditSecret)
+		} else {
+			replaced = key
+			replaced.Secret, err = otp.DecodeSecret(*flagEditSecret)
+		}
+		if err != nil {
+			return false, err
+		}
+		secmem.Wipe(key.Secret)
+		key = replaced
+	}
+	if flagEditIssuer != nil {
+		key.Issuer = *flagEditIssuer
+	}
+	if flagEditAccount != nil {
+		key.Account = *flagEditAccount
+	}
+	if flagEditAlgorithm != nil {
+		key.Algorithm = strings.ToUpper(*flagEditAlgorithm)
+	}
+	if flagEditDigits != nil {
+		if key.Digits, err = strconv.Atoi(*flagEditDigits); err != nil {
+			return false, fmt.Errorf("digits %q must be a number", *flagEditDigits)
+		}
+	}
+	if flagEditPeriod != nil {
+		if key.Period, err = strconv.Atoi(*flagEditPeriod); err != nil {
+			return false, fmt.Errorf("period %q must be a number", *flagEditPeriod)
+		}
+	}
+	if flagEditCounter != nil {
+		if key.Counter, err = strconv.ParseUint(*flagEditCounter, 10, 64); err != nil {
+			return false, fmt.Errorf("counter %q must be a non-negative number", *flagEditCounter)
+		}
+	}
+
+	return true, v.UpdateOTP(id, flagEditDescription, key)
+}
+
+// fieldIndex возвращает индекс дополнительного поля с именем name или -1.
+func fieldIndex(fields []vault.CustomField, name string) int {
+	for i, cf := range fields {
+		if cf.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// editNote заменяет текстовую заметку содержимым файла или текстом,
+// изменённым в редакторе.
+func editNote(v *vault.Vault, id string, editor bool) (bool, error) {
+	var note *secmem.Buffer
+	var err error
+
+	switch {
+	case flagEditFile != "":
+		note, err = readInput(flagEditFile)
+	case editor:
+		note, err = editInEditor(v, id, noteName)
+	default:
+		return false, nil
+	}
+	if err != nil || note == nil {
+		return false, err
+	}
+	defer note.Destroy()
+
+	if err = v.UpdateNote(id, flagEditDescription, note.Bytes()); err != nil {
+		return false, fmt.Errorf("%w, use gk rm %s to delete it", err, id)
+	}
+
+	return true, nil
+}
+
+// editEnv заменяет набор переменных окружения содержимым файла в формате
+// .env или набором, изменённым в редакторе.
+func editEnv(v *vault.Vault, id string, editor bool) (bool, error) {
+	var data *secmem.Buffer
+	var err error
+
+	switch {
+	case flagEditFile != "":
+		data, err = readInput(flagEditFile)
+	case editor:
+		data, err = editEnvInEditor(v, id)
+	default:
+		return false, nil
+	}
+	if err != nil || data == nil {
+		return false, err
+	}
+	defer data.Destroy()
+
+	env, err := vault.ParseEnv(data.Bytes())
+	if err != nil {
+		return false, err
+	}
+	defer env.Wipe()
+
+	return true, v.UpdateEnv(id, flagEditDescription, env)
+}
+
+// editEnvInEditor открывает набор переменных окружения с ID id в редакторе в
+// формате .env и возвращает изменённый текст или nil, если он не изменился.
+func editEnvInEditor(v *vault.Vault, id string) (*secmem.Buffer, error) {
+	env, err := v.Env(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := env.AppendFormat(nil, vault.Format{})
+	env.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	defer secmem.Wipe(b)
+
+	return editText(envName, b)
+}
+
+// editFile заменяет содержимое файла содержимым файла, переданного флагом
+// -file.
+func editFile(v *vault.Vault, id string) (bool, error) {
+	if flagEditFile == "" {
+		return false, nil
+	}
+
+	var src io.Reader = os.Stdin
+	if flagEditFile != "-" {
+		f, err := os.Open(flagEditFile)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	return true, v.Edit(id, flagEditDescription, src)
+}
+
+// editInEditor открывает текущее содержимое данных с ID id в редакторе и
+// возвращает изменённое содержимое или nil, если оно не изменилось.
+func editInEditor(v *vault.Vault, id, name string) (*secmem.Buffer, error) {
+	src, err := v.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := secmem.ReadAll(src)
+	_ = src.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Destroy()
+
+	return editText(name, data.Bytes())
+}
+
+// editText открывает text в редакторе и возвращает изменённый текст или nil,
+// если он не изменился.
+func editText(name string, text []byte) (*secmem.Buffer, error) {
+	edited, err := cliutil.Edit(name, text)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(edited.Bytes(), text) {
+		edited.Destroy()
+		return nil, nil
+	}
+	return edited, nil
+}
@@ -0,0 +1,130 @@
+package gophkeeper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+var flagExecEnv string // ID наборов переменных окружения через запятую.
+
+// AddEnv добавляет в хранилище переменные окружения из файла .env или из
+// стандартного ввода, если вместо пути указан "-".
+func AddEnv(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	data, err := readInput(args[0])
+	if err != nil {
+		return err
+	}
+	defer data.Destroy()
+
+	env, err := vault.ParseEnv(data.Bytes())
+	if err != nil {
+		return err
+	}
+	defer env.Wipe()
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.AddEnv(flagDescription, env, flagRecipients...); err != nil {
+		return err
+	}
+
+	fmt.Println("the data has been successfully added")
+
+	return nil
+}
+
+// readInput считывает в защищённую память содержимое файла path или
+// стандартного ввода, если path равен -.
+func readInput(path string) (*secmem.Buffer, error) {
+	if path == "-" {
+		return secmem.ReadAll(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return secmem.ReadAll(f)
+}
+
+// Exec запускает команду с переменными окружения из хранилища. Переменные
+// передаются процессу напрямую и не записываются на диск; переменные
+// последующих наборов переопределяют предыдущие и окружение gk.
+func Exec(args []string) error {
+	if flagExecEnv == "" {
+		return errors.New("-env is required")
+	}
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	environ, err := loadEnv(strings.Split(flagExecEnv, ","))
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = environ
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	// Сигналы, полученные gk, передаются команде, чтобы она могла
+	// корректно завершиться.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	return cmd.Wait()
+}
+
+// loadEnv возвращает окружение gk, дополненное переменными из наборов с
+// ID ids.
+func loadEnv(ids []string) ([]string, error) {
+	v, err := vault.NewVault()
+	if err != nil {
+		return nil, err
+	}
+	defer v.Close()
+
+	environ := os.Environ()
+
+	for _, id := range ids {
+		env, err := v.Env(strings.TrimSpace(id))
+		if err != nil {
+			return nil, err
+		}
+		environ = env.Environ(environ)
+		env.Wipe()
+	}
+
+	return environ, nil
+}
@@ -0,0 +1,70 @@
+package gophkeeper
+
+import (
+	"flag"
+	"os"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/passgen"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+var (
+	flagGenLength      int    // Длина пароля.
+	flagGenNoLower     bool   // Без строчных букв.
+	flagGenNoUpper     bool   // Без прописных букв.
+	flagGenNoDigits    bool   // Без цифр.
+	flagGenNoSymbols   bool   // Без специальных символов.
+	flagGenNoAmbiguous bool   // Без похожих символов.
+	flagGenPassphrase  bool   // Парольная фраза вместо пароля.
+	flagGenWords       int    // Количество слов парольной фразы.
+	flagGenSeparator   string // Разделитель слов парольной фразы.
+	flagGenCapitalize  bool   // Слова парольной фразы с прописной буквы.
+
+	flagGenerate bool // Сгенерировать пароль учётной записи.
+)
+
+// genFlags регистрирует флаги генерации пароля.
+func genFlags(fs *flag.FlagSet) {
+	fs.IntVar(&flagGenLength, "length", passgen.DefaultPolicy.Length, "password length")
+	fs.BoolVar(&flagGenNoLower, "no-lower", false, "exclude lowercase letters")
+	fs.BoolVar(&flagGenNoUpper, "no-upper", false, "exclude uppercase letters")
+	fs.BoolVar(&flagGenNoDigits, "no-digits", false, "exclude digits")
+	fs.BoolVar(&flagGenNoSymbols, "no-symbols", false, "exclude symbols")
+	fs.BoolVar(&flagGenNoAmbiguous, "no-ambiguous", false, "exclude look-alike characters such as 0, O, 1, l and I")
+	fs.BoolVar(&flagGenPassphrase, "passphrase", false, "generate a passphrase of random words instead")
+	fs.IntVar(&flagGenWords, "words", passgen.DefaultPassphrasePolicy.Words, "number of words in a passphrase")
+	fs.StringVar(&flagGenSeparator, "separator", passgen.DefaultPassphrasePolicy.Separator, "separator of words in a passphrase")
+	fs.BoolVar(&flagGenCapitalize, "capitalize", false, "capitalize words in a passphrase")
+}
+
+// generatePassword генерирует пароль или парольную фразу по флагам.
+func generatePassword() ([]byte, error) {
+	if flagGenPassphrase {
+		return passgen.Passphrase(passgen.PassphrasePolicy{
+			Words:      flagGenWords,
+			Separator:  flagGenSeparator,
+			Capitalize: flagGenCapitalize,
+		})
+	}
+	return passgen.Password(passgen.Policy{
+		Length:           flagGenLength,
+		Lower:            !flagGenNoLower,
+		Upper:            !flagGenNoUpper,
+		Digits:           !flagGenNoDigits,
+		Symbols:          !flagGenNoSymbols,
+		ExcludeAmbiguous: flagGenNoAmbiguous,
+	})
+}
+
+// Gen выводит сгенерированный пароль или парольную фразу.
+func Gen([]string) error {
+	password, err := generatePassword()
+	if err != nil {
+		return err
+	}
+	password = append(password, '\n')
+	defer secmem.Wipe(password)
+
+	_, err = os.Stdout.Write(password)
+	return err
+}
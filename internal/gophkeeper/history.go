@@ -0,0 +1,100 @@
+package gophkeeper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rodaine/table"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+var flagHistoryLimit int // Количество хранимых прежних паролей.
+
+// ChangeLoginPassword заменяет пароль учётной записи, сохраняя прежний в
+// истории.
+func ChangeLoginPassword(args []string) error {
+	if len(args) < 1 || (len(args) < 2 && !flagGenerate) {
+		return errArgsTooSmall
+	}
+
+	var password []byte
+	if flagGenerate {
+		if len(args) > 1 {
+			return errors.New("the password must not be passed with -generate")
+		}
+		var err error
+		if password, err = generatePassword(); err != nil {
+			return err
+		}
+	} else {
+		password = []byte(args[1])
+	}
+	defer secmem.Wipe(password)
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.ChangeLoginPassword(args[0], password, time.Now()); err != nil {
+		return err
+	}
+
+	fmt.Println("the password has been successfully changed")
+
+	return nil
+}
+
+// History выводит прежние пароли учётной записи или задаёт их количество,
+// хранимое на текущем устройстве.
+func History(args []string) error {
+	if len(args) < 1 && flagHistoryLimit < 0 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if flagHistoryLimit >= 0 {
+		if err = v.SetHistoryLimit(flagHistoryLimit); err != nil {
+			return err
+		}
+		fmt.Printf("up to %d previous passwords will be kept\n", flagHistoryLimit)
+		if len(args) < 1 {
+			return nil
+		}
+	}
+
+	up, err := v.Login(args[0])
+	if err != nil {
+		return err
+	}
+	defer up.Wipe()
+
+	if len(up.History) == 0 {
+		fmt.Println("the password has never been changed")
+		return nil
+	}
+
+	tb := table.New("CHANGED AT", "PASSWORD")
+
+	for _, prev := range up.History {
+		password := string(prev.Password)
+		if flagMask {
+			password = strings.Repeat("*", len(prev.Password))
+		}
+		tb.AddRow(prev.ChangedAt.Local().Format("2006-01-02 15:04"), password)
+	}
+
+	tb.Print()
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package gophkeeper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rodaine/table"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+)
+
+var flagRecipients []*cryptio.Recipient // Получатели данных.
+
+// addRecipient добавляет получателя данных по открытому ключу или по пути к
+// файлу с ним.
+func addRecipient(value string) error {
+	recipient, err := cryptio.ParseRecipient(value)
+	if err == nil {
+		flagRecipients = append(flagRecipients, recipient)
+		return nil
+	}
+
+	b, err := os.ReadFile(value)
+	if err != nil {
+		return err
+	}
+
+	if recipient, err = cryptio.ParseRecipient(string(b)); err != nil {
+		return fmt.Errorf("%s: %w", value, err)
+	}
+	flagRecipients = append(flagRecipients, recipient)
+
+	return nil
+}
+
+// KeysGenerate генерирует закрытый ключ получателя и выводит открытый ключ.
+func KeysGenerate([]string) (err error) {
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	var f *os.File
+	if flagOutput != "" {
+		f, err = os.OpenFile(flagOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+			if err != nil {
+				_ = os.Remove(f.Name())
+			}
+		}()
+	}
+
+	id, err := v.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+
+	if f != nil {
+		if _, err = fmt.Fprintln(f, id); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(id.Recipient())
+
+	return nil
+}
+
+// KeysImport импортирует закрытый ключ получателя из файла.
+func KeysImport(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	b, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	id, err := cryptio.ParseIdentity(strings.TrimSpace(string(b)))
+	if err != nil {
+		return err
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.ImportIdentity(id); err != nil {
+		return err
+	}
+
+	fmt.Printf("the identity %s has been imported\n", id.Recipient())
+
+	return nil
+}
+
+// KeysList выводит открытые ключи получателя, закрытые ключи которых есть в
+// хранилище.
+func KeysList([]string) error {
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	tb := table.New("RECIPIENT", "CREATED")
+
+	for _, id := range v.Identities() {
+		tb.AddRow(id.Recipient, id.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	tb.Print()
+
+	return nil
+}
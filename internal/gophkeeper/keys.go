@@ -0,0 +1,136 @@
+package gophkeeper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+)
+
+var (
+	flagKDFTime    uint // Количество проходов Argon2id.
+	flagKDFMemory  uint // Объём памяти Argon2id, КиБ.
+	flagKDFThreads uint // Степень параллелизма Argon2id.
+
+	flagKeyFile   string // Путь к ключевому файлу.
+	flagNoKeyFile bool   // Отказ от ключевого файла.
+)
+
+// Init инициализирует хранилище на текущем устройстве.
+func Init([]string) error {
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.Init(flagKeyFile); err != nil {
+		return err
+	}
+
+	fmt.Println("the vault has been initialized")
+
+	return nil
+}
+
+// Migrate перешифровывает данные хранилища основным ключом. Если заданы
+// параметры Argon2id, то предварительно создаётся новый основной ключ.
+func Migrate([]string) error {
+	if flagKDFTime > math.MaxUint32 || flagKDFMemory > math.MaxUint32 {
+		return errors.New("kdf parameters are too large")
+	}
+	if flagKDFThreads > math.MaxUint8 {
+		return errors.New("kdf threads must not exceed 255")
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if flagKDFTime != 0 || flagKDFMemory != 0 || flagKDFThreads != 0 {
+		_, err = v.NewKey(uint32(flagKDFTime), uint32(flagKDFMemory), uint8(flagKDFThreads))
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := v.Migrate()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d items have been re-encrypted\n", n)
+
+	if dir := v.LegacyCopies(); dir != "" {
+		fmt.Printf("the master password could not be verified, copies of legacy items are kept in %s;\n", dir)
+		fmt.Println("remove them once the items are readable")
+	}
+
+	return nil
+}
+
+// Passwd меняет мастер-пароль и, если задано, ключевой файл хранилища.
+func Passwd([]string) error {
+	keyfile, err := keyFileFlag()
+	if err != nil {
+		return err
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	n, err := v.ChangePassword(keyfile)
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		fmt.Printf("%d items have been re-encrypted\n", n)
+	}
+	fmt.Println("the master password has been changed")
+
+	return nil
+}
+
+// keyFileFlag возвращает изменение ключевого файла, заданное флагами: nil,
+// если ключевой файл не меняется, и пустую строку, если он отменяется.
+func keyFileFlag() (*string, error) {
+	switch {
+	case flagKeyFile != "" && flagNoKeyFile:
+		return nil, errors.New("-keyfile and -no-keyfile are mutually exclusive")
+	case flagKeyFile != "":
+		return &flagKeyFile, nil
+	case flagNoKeyFile:
+		return new(string), nil
+	default:
+		return nil, nil
+	}
+}
+
+// Rotate перешифровывает данные новыми собственными ключами.
+func Rotate(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	n, err := v.Rotate(args...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d items have been re-encrypted\n", n)
+
+	return nil
+}
@@ -29,6 +29,7 @@ func Login([]string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
 	remote := v.GetRemote()
 	if remote.Address == "" {
@@ -0,0 +1,34 @@
+package gophkeeper
+
+import (
+	"fmt"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/cliutil"
+)
+
+// noteName определяет имя временного файла заметки в редакторе.
+const noteName = "note.txt"
+
+// AddNote добавляет в хранилище текстовую заметку, набранную в редакторе.
+func AddNote([]string) error {
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	note, err := cliutil.Edit(noteName, nil)
+	if err != nil {
+		return err
+	}
+	defer note.Destroy()
+
+	if err = v.AddNote(flagDescription, note.Bytes(), flagRecipients...); err != nil {
+		return err
+	}
+
+	fmt.Println("the data has been successfully added")
+
+	return nil
+}
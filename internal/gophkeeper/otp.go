@@ -0,0 +1,100 @@
+package gophkeeper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+)
+
+var (
+	flagOTPHOTP      bool   // Ключ HOTP вместо TOTP.
+	flagOTPCounter   uint64 // Начальное значение счётчика HOTP.
+	flagOTPDigits    int    // Количество цифр пароля.
+	flagOTPPeriod    int    // Период действия пароля TOTP.
+	flagOTPAlgorithm string // Алгоритм HMAC.
+	flagOTPIssuer    string // Сервис, выдавший ключ.
+	flagOTPAccount   string // Учётная запись.
+)
+
+// AddOTP добавляет в хранилище ключ генерации одноразовых паролей, заданный
+// otpauth:// URI или секретом в кодировке base32.
+func AddOTP(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	key, err := parseOTPKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.AddOTP(flagDescription, key, flagRecipients...); err != nil {
+		return err
+	}
+
+	fmt.Println("the data has been successfully added")
+
+	return nil
+}
+
+// parseOTPKey разбирает otpauth:// URI или секрет в кодировке base32 с
+// параметрами из флагов.
+func parseOTPKey(s string) (otp.Key, error) {
+	if strings.HasPrefix(s, "otpauth://") {
+		return otp.Parse(s)
+	}
+
+	kind := otp.TOTP
+	if flagOTPHOTP {
+		kind = otp.HOTP
+	}
+
+	key, err := otp.NewKey(kind, s)
+	if err != nil {
+		return otp.Key{}, err
+	}
+
+	key.Issuer = flagOTPIssuer
+	key.Account = flagOTPAccount
+	key.Algorithm = strings.ToUpper(flagOTPAlgorithm)
+	key.Digits = flagOTPDigits
+	key.Period = flagOTPPeriod
+	key.Counter = flagOTPCounter
+
+	return key, key.Validate()
+}
+
+// OTP выводит текущий одноразовый пароль.
+func OTP(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	code, err := v.OTP(args[0], time.Now())
+	if err != nil {
+		return err
+	}
+
+	if code.Kind == otp.HOTP {
+		fmt.Printf("%s (counter %d)\n", code.Code, code.Counter)
+	} else {
+		fmt.Printf("%s (valid for %ds)\n", code.Code, int(code.Remaining.Round(time.Second)/time.Second))
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package gophkeeper
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/cliutil"
+)
+
+// Переменные окружения с источниками мастер-пароля.
+const (
+	envPassword        = "GK_PASSWORD"         // Мастер-пароль.
+	envPasswordFile    = "GK_PASSWORD_FILE"    // Файл с мастер-паролем.
+	envPasswordCommand = "GK_PASSWORD_COMMAND" // Команда, выводящая мастер-пароль.
+)
+
+var (
+	flagPasswordFD      int    // Файловый дескриптор с мастер-паролем.
+	flagPasswordFile    string // Файл с мастер-паролем.
+	flagPasswordCommand string // Команда, выводящая мастер-пароль.
+)
+
+// passwordFlags регистрирует флаги источников мастер-пароля.
+func passwordFlags(fs *flag.FlagSet) {
+	fs.IntVar(&flagPasswordFD, "password-fd", -1, "read the master password from the file descriptor")
+	fs.StringVar(&flagPasswordFile, "password-file", "", "read the master password from the file")
+	fs.StringVar(&flagPasswordCommand, "password-command", "", "read the master password from the output of the shell command")
+}
+
+// setPasswordSource устанавливает источник мастер-пароля.
+//
+// Флаги имеют приоритет над переменными окружения, а и те и другие — над
+// терминалом. Одновременно может быть задан только один флаг и только одна
+// переменная окружения. Переменные удаляются из окружения, чтобы их не
+// наследовали дочерние процессы: команда gk exec, редактор, команда,
+// выводящая мастер-пароль, и агент.
+func setPasswordSource() error {
+	defer func() {
+		for _, name := range []string{envPassword, envPasswordFile, envPasswordCommand} {
+			_ = os.Unsetenv(name)
+		}
+	}()
+
+	var flags []cliutil.PasswordSource
+	if flagPasswordFD >= 0 {
+		flags = append(flags, cliutil.PasswordFD(uintptr(flagPasswordFD)))
+	}
+	if flagPasswordFile != "" {
+		flags = append(flags, cliutil.PasswordFile(flagPasswordFile))
+	}
+	if flagPasswordCommand != "" {
+		flags = append(flags, cliutil.PasswordCommand(flagPasswordCommand))
+	}
+
+	switch len(flags) {
+	case 0:
+	case 1:
+		cliutil.SetPasswordSource(flags[0])
+		return nil
+	default:
+		return errors.New("only one of --password-fd, --password-file and --password-command may be specified")
+	}
+
+	var envs []cliutil.PasswordSource
+	if path := os.Getenv(envPasswordFile); path != "" {
+		envs = append(envs, cliutil.PasswordFile(path))
+	}
+	if command := os.Getenv(envPasswordCommand); command != "" {
+		envs = append(envs, cliutil.PasswordCommand(command))
+	}
+	if _, ok := os.LookupEnv(envPassword); ok {
+		envs = append(envs, cliutil.PasswordEnv(envPassword))
+	}
+
+	switch len(envs) {
+	case 0:
+	case 1:
+		cliutil.SetPasswordSource(envs[0])
+	default:
+		return errors.New("only one of " + envPassword + ", " + envPasswordFile + " and " + envPasswordCommand + " may be set")
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package gophkeeper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/shamir"
+)
+
+var (
+	flagShares    int // Количество долей.
+	flagThreshold int // Количество долей для восстановления.
+)
+
+// RecoverySplit разделяет ключи хранилища на доли для восстановления.
+func RecoverySplit([]string) error {
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	secret, err := v.RecoverySecret()
+	if err != nil {
+		return err
+	}
+
+	shares, err := shamir.Split(secret, flagShares, flagThreshold)
+	if err != nil {
+		return err
+	}
+
+	for i, share := range shares {
+		fmt.Printf("share %d of %d, any %d recover the vault:\n%s\n\n", i+1, len(shares), flagThreshold, share)
+	}
+
+	return nil
+}
+
+// RecoveryCombine восстанавливает ключи хранилища из долей и устанавливает
+// новый мастер-пароль.
+func RecoveryCombine([]string) error {
+	keyfile, err := keyFileFlag()
+	if err != nil {
+		return err
+	}
+
+	shares, err := readShares()
+	if err != nil {
+		return err
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		return err
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	n, err := v.Recover(secret, keyfile)
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		fmt.Printf("%d items have been re-encrypted\n", n)
+	}
+	fmt.Println("the vault has been recovered, the master password has been changed")
+
+	return nil
+}
+
+// readShares считывает доли из стандартного ввода, пока их не будет
+// достаточно для восстановления. Пустые строки и заголовки долей
+// пропускаются.
+func readShares() ([]shamir.Share, error) {
+	sc := bufio.NewScanner(os.Stdin)
+
+	var shares []shamir.Share
+	for len(shares) == 0 || len(shares) < int(shares[0].Threshold) {
+		fmt.Printf("Share %d: ", len(shares)+1)
+
+		if !sc.Scan() {
+			fmt.Println()
+			if err := sc.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("there are too few shares")
+		}
+
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "share ") {
+			continue
+		}
+
+		share, err := shamir.Parse(line)
+		if err != nil {
+			fmt.Printf("\n%s, try again\n", err)
+			continue
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
@@ -23,6 +23,7 @@ func RemoteSet(args []string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
 	return v.SetRemoteAddress(args[0])
 }
@@ -33,6 +34,7 @@ func RemoteShow([]string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
 	remote := v.GetRemote()
 	if remote.Address != "" {
@@ -48,6 +50,7 @@ func Sync([]string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
 	remote := v.GetRemote()
 	if remote.Address == "" {
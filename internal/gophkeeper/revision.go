@@ -0,0 +1,89 @@
+package gophkeeper
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rodaine/table"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+)
+
+var (
+	flagRevision      int // Номер восстанавливаемой версии.
+	flagRevisionLimit int // Количество хранимых прежних версий.
+)
+
+// Log выводит версии данных или задаёт количество прежних версий, хранимое
+// на текущем устройстве.
+func Log(args []string) error {
+	if len(args) < 1 && flagRevisionLimit < 0 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if flagRevisionLimit >= 0 {
+		if err = v.SetRevisionLimit(flagRevisionLimit); err != nil {
+			return err
+		}
+		fmt.Printf("up to %d previous revisions will be kept\n", flagRevisionLimit)
+		if len(args) < 1 {
+			return nil
+		}
+	}
+
+	files, err := v.Log(args[0])
+	if err != nil {
+		return err
+	}
+
+	tb := table.New("REV", "LAST UPDATE", "DEVICE", "SIZE", "DESCRIPTION")
+
+	for i, file := range files {
+		rev := strconv.Itoa(file.Revision)
+		if i == 0 {
+			rev += " (current)"
+		}
+		device, size := file.Device, "-"
+		if device == "" {
+			device = "-"
+		}
+		if file.Size > 0 {
+			size = strconv.FormatInt(file.Size, 10)
+		}
+		tb.AddRow(rev, file.LastUpdate.Local().Format("2006-01-02 15:04"), device, size, file.Description)
+	}
+
+	tb.Print()
+
+	return nil
+}
+
+// Restore делает прежнюю версию данных текущей.
+func Restore(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+	if flagRevision <= 0 {
+		return fmt.Errorf("revision must be passed with -rev, see gk log %s", args[0])
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	if err = v.Restore(args[0], flagRevision); err != nil {
+		return err
+	}
+
+	fmt.Printf("revision %d has been successfully restored\n", flagRevision)
+
+	return nil
+}
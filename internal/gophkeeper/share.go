@@ -0,0 +1,75 @@
+package gophkeeper
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sergeizaitcev/gophkeeper/internal/vault"
+	"github.com/sergeizaitcev/gophkeeper/pkg/workdir"
+)
+
+// Export выводит архив с зашифрованными данными для передачи получателям.
+func Export(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	archive, err := v.Export(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = archive.Close()
+		_ = os.Remove(archive.Name())
+	}()
+
+	dst := os.Stdout
+	if flagOutput != "" {
+		dst, err = os.OpenFile(flagOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, workdir.FileMode)
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+	}
+
+	if _, err = io.Copy(dst, archive); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Import добавляет в хранилище зашифрованные данные из архива.
+func Import(args []string) error {
+	if len(args) < 1 {
+		return errArgsTooSmall
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	v, err := vault.NewVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	n, err := v.Import(f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d items have been imported\n", n)
+
+	return nil
+}
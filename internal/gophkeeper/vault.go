@@ -1,9 +1,12 @@
 package gophkeeper
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/rodaine/table"
 
@@ -14,8 +17,41 @@ import (
 var (
 	flagDescription string // Описание данных.
 	flagOutput      string // Вывод данных.
+	flagField       string // Выводимое поле данных.
+	flagMask        bool   // Маскировать секретные поля.
+
+	flagCardHolder string // Имя держателя карты.
+	flagCardExpiry string // Срок действия карты.
+	flagCardCVV    string // CVV карты.
+
+	flagLoginURLs   []string            // Адреса сайтов учётной записи.
+	flagLoginNotes  string              // Заметки к учётной записи.
+	flagLoginFields []vault.CustomField // Дополнительные поля учётной записи.
 )
 
+// addLoginURL добавляет адрес сайта учётной записи.
+func addLoginURL(value string) error {
+	flagLoginURLs = append(flagLoginURLs, value)
+	return nil
+}
+
+// addLoginField возвращает функцию, добавляющую дополнительное поле вида
+// kind, заданное как name=value.
+func addLoginField(kind vault.FieldKind) func(string) error {
+	return func(value string) error {
+		name, value, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("%s field must be name=value", kind)
+		}
+		flagLoginFields = append(flagLoginFields, vault.CustomField{
+			Name:  name,
+			Kind:  kind,
+			Value: []byte(value),
+		})
+		return nil
+	}
+}
+
 // AddBankCard добавляет данные банковской карты в хранилище.
 func AddBankCard(args []string) error {
 	if len(args) < 1 {
@@ -23,18 +59,31 @@ func AddBankCard(args []string) error {
 	}
 
 	card := vault.NewBankCard(args[0])
+	defer card.Wipe()
 
-	err := card.Validate()
-	if err != nil {
+	card.Holder = flagCardHolder
+	card.CVV = []byte(flagCardCVV)
+
+	var err error
+	if flagCardExpiry != "" {
+		if card.Expiry, err = vault.ParseExpiry(flagCardExpiry); err != nil {
+			return err
+		}
+	}
+	if err = card.Validate(); err != nil {
 		return err
 	}
+	if card.Expiry.Expired(time.Now()) {
+		fmt.Fprintf(os.Stderr, "warning: the card expired in %s\n", card.Expiry)
+	}
 
 	v, err := vault.NewVault()
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
-	if err = v.AddBankCard(flagDescription, card); err != nil {
+	if err = v.AddBankCard(flagDescription, card, flagRecipients...); err != nil {
 		return err
 	}
 
@@ -44,12 +93,31 @@ func AddBankCard(args []string) error {
 }
 
 // AddUsernamePassword добавляет учетные данные пользователя в хранилище.
+// С флагом -generate пароль не передаётся, а генерируется.
 func AddUsernamePassword(args []string) error {
-	if len(args) < 2 {
+	if len(args) < 1 || (len(args) < 2 && !flagGenerate) {
 		return errArgsTooSmall
 	}
 
-	logpass := vault.NewUsernamePassword(args[0], args[1])
+	var logpass vault.UsernamePassword
+	if flagGenerate {
+		if len(args) > 1 {
+			return errors.New("the password must not be passed with -generate")
+		}
+		password, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		logpass = vault.UsernamePassword{Username: args[0], Password: password}
+	} else {
+		logpass = vault.NewUsernamePassword(args[0], args[1])
+	}
+	defer logpass.Wipe()
+
+	logpass.URLs = flagLoginURLs
+	logpass.Notes = flagLoginNotes
+	logpass.Fields = flagLoginFields
+
 	err := logpass.Validate()
 	if err != nil {
 		return err
@@ -59,8 +127,9 @@ func AddUsernamePassword(args []string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
-	if err = v.AddLoginPassword(flagDescription, logpass); err != nil {
+	if err = v.AddLoginPassword(flagDescription, logpass, flagRecipients...); err != nil {
 		return err
 	}
 
@@ -85,8 +154,9 @@ func AddFile(args []string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
-	if err = v.Add(flagDescription, f); err != nil {
+	if err = v.Add(flagDescription, f, flagRecipients...); err != nil {
 		return err
 	}
 
@@ -105,6 +175,7 @@ func Remove(args []string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
 	if err = v.Del(args[0]); err != nil {
 		return err
@@ -121,15 +192,18 @@ func List([]string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
+
+	files, err := v.List()
+	if err != nil {
+		return err
+	}
 
 	tb := table.New("ID", "TYPE", "DESCRIPTION")
 
-	_ = v.Walk(func(file vault.File) error {
-		if !file.IsDeleted {
-			tb.AddRow(file.ID, file.Type, file.Description)
-		}
-		return nil
-	})
+	for _, file := range files {
+		tb.AddRow(file.ID, file.Type, file.Description)
+	}
 
 	tb.Print()
 
@@ -146,8 +220,14 @@ func Show(args []string) error {
 	if err != nil {
 		return err
 	}
+	defer v.Close()
 
-	src, err := v.Get(args[0])
+	var src io.ReadCloser
+	if flagAttachment != "" {
+		src, err = v.Attachment(args[0], flagAttachment)
+	} else {
+		src, err = v.Show(args[0], vault.Format{Field: flagField, Mask: flagMask})
+	}
 	if err != nil {
 		return err
 	}
@@ -132,11 +132,17 @@ func merge(name string, src io.Reader) (io.ReadCloser, error) {
 
 	current, replacement := tar.NewReader(bufio.NewReader(f)), tar.NewReader(src)
 
-	files, err := mergeFiles(current, replacement)
+	currentIndex, err := readIndex(current)
+	if err != nil {
+		return nil, err
+	}
+	replacementIndex, err := readIndex(replacement)
 	if err != nil {
 		return nil, err
 	}
 
+	merged := replacementIndex.merge(currentIndex)
+
 	temp, err := os.CreateTemp(filepath.Dir(name), "temp-*.tar")
 	if err != nil {
 		return nil, err
@@ -146,13 +152,15 @@ func merge(name string, src io.Reader) (io.ReadCloser, error) {
 	buf := bufio.NewWriter(temp)
 	dst := tar.NewWriter(buf)
 
-	if err = writeFiles(dst, files); err != nil {
+	written := make(map[string]bool)
+
+	if err = writeIndex(dst, merged); err != nil {
 		return nil, err
 	}
-	if err = copyDataBy(dst, current, files, false); err != nil {
+	if err = copyDataBy(dst, current, merged.files, currentIndex.files, written, false); err != nil {
 		return nil, err
 	}
-	if err = copyDataBy(dst, replacement, files, true); err != nil {
+	if err = copyDataBy(dst, replacement, merged.files, replacementIndex.files, written, true); err != nil {
 		return nil, err
 	}
 
@@ -181,14 +189,14 @@ func save(src io.Reader) (string, error) {
 	tw := tar.NewWriter(buf)
 	tr := tar.NewReader(src)
 
-	files, err := readFiles(tr)
+	idx, err := readIndex(tr)
 	if err != nil {
 		return "", err
 	}
-	if err = writeFiles(tw, files); err != nil {
+	if err = writeIndex(tw, idx); err != nil {
 		return "", err
 	}
-	if err = copyDataBy(tw, tr, files, false); err != nil {
+	if err = copyDataBy(tw, tr, idx.files, idx.files, make(map[string]bool), false); err != nil {
 		return "", err
 	}
 	if err = tw.Close(); err != nil {
@@ -212,48 +220,66 @@ func switchFile(dst, src *os.File) error {
 	return os.Rename(oldpath, newpath)
 }
 
-func mergeFiles(current, replacement *tar.Reader) (vault.Files, error) {
-	currentFiles, err := readFiles(current)
-	if err != nil {
-		return nil, err
-	}
-	replacementFiles, err := readFiles(replacement)
-	if err != nil {
-		return nil, err
+// index определяет индекс архива: набор ключей и конфигурацию файлов.
+type index struct {
+	keys  vault.Keyring
+	files vault.Files
+}
+
+// merge объединяет индексы архивов в один и возвращает его. Вложения и
+// прежние версии удалённых элементов исключаются из индекса вместе с ними,
+// поэтому их данные не попадают в объединённый архив.
+func (idx index) merge(x index) index {
+	return index{
+		keys:  idx.keys.Merge(x.keys),
+		files: idx.files.Merge(x.files),
 	}
-	return replacementFiles.Merge(currentFiles), nil
 }
 
-func readFiles(tr *tar.Reader) (vault.Files, error) {
+// readIndex считывает индекс архива. Набор ключей предшествует конфигурации
+// файлов и может отсутствовать в архивах старых клиентов.
+func readIndex(tr *tar.Reader) (index, error) {
+	var idx index
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return index{}, err
 		}
-		if hdr.Name == vault.FilesName {
-			var fs vault.Files
-			if _, err = fs.ReadFrom(io.LimitReader(tr, hdr.Size)); err != nil {
-				return nil, err
+		switch hdr.Name {
+		case vault.KeysName:
+			if _, err = idx.keys.ReadFrom(io.LimitReader(tr, hdr.Size)); err != nil {
+				return index{}, err
+			}
+		case vault.FilesName:
+			if _, err = idx.files.ReadFrom(io.LimitReader(tr, hdr.Size)); err != nil {
+				return index{}, err
 			}
-			return fs, nil
+			return idx, nil
 		}
 	}
-	return nil, ErrNotFound
+	return index{}, ErrNotFound
+}
+
+func writeIndex(tw *tar.Writer, idx index) error {
+	if err := writeConfig(tw, vault.KeysName, idx.keys); err != nil {
+		return err
+	}
+	return writeConfig(tw, vault.FilesName, idx.files)
 }
 
-func writeFiles(tw *tar.Writer, files vault.Files) error {
+func writeConfig(tw *tar.Writer, name string, src io.WriterTo) error {
 	var buf bytes.Buffer
 
-	n, err := files.WriteTo(&buf)
+	n, err := src.WriteTo(&buf)
 	if err != nil {
 		return err
 	}
 
 	hdr := &tar.Header{
-		Name:     vault.FilesName,
+		Name:     name,
 		Typeflag: tar.TypeReg,
 		Size:     n,
 		Mode:     workdir.FileMode,
@@ -269,7 +295,15 @@ func writeFiles(tw *tar.Writer, files vault.Files) error {
 	return nil
 }
 
-func copyDataBy(dst *tar.Writer, src *tar.Reader, files vault.Files, skipDir bool) error {
+// copyDataBy копирует из src в dst зашифрованные файлы, актуальные версии
+// которых определены в files; side определяет конфигурацию файлов src.
+func copyDataBy(
+	dst *tar.Writer,
+	src *tar.Reader,
+	files, side vault.Files,
+	written map[string]bool,
+	skipDir bool,
+) error {
 	for {
 		hdr, err := src.Next()
 		if err == io.EOF {
@@ -289,16 +323,21 @@ func copyDataBy(dst *tar.Writer, src *tar.Reader, files vault.Files, skipDir boo
 		}
 
 		file, i := files.Lookup(filepath.Base(hdr.Name))
-		if i < 0 || file.IsDeleted {
+		if i < 0 || file.IsDeleted || written[file.ID] {
+			continue
+		}
+		if own, j := side.Lookup(file.ID); j < 0 || own.SHA256 != file.SHA256 {
 			continue
 		}
 
 		if err = dst.WriteHeader(hdr); err != nil {
 			return err
 		}
-		if _, _ = io.CopyN(dst, src, hdr.Size); err != nil {
+		if _, err = io.CopyN(dst, src, hdr.Size); err != nil {
 			return err
 		}
+
+		written[file.ID] = true
 	}
 	return nil
 }
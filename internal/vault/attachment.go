@@ -0,0 +1,84 @@
+package vault
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Attach добавляет к элементу с ID id вложение name с содержимым src.
+// Вложение шифруется собственным ключом для тех же получателей, что и
+// элемент, синхронизируется вместе с ним и удаляется при его удалении.
+func (v *Vault) Attach(id, name string, src io.Reader) error {
+	parent, err := v.Stat(id)
+	if err != nil {
+		return err
+	}
+	if parent.Parent != "" {
+		return fmt.Errorf("%s is an attachment itself", id)
+	}
+	if parent.Revises != "" {
+		return fmt.Errorf("%s is a revision of %s and cannot have attachments", id, parent.Revises)
+	}
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "/\\\r\n") {
+		return fmt.Errorf("attachment name %q is invalid", name)
+	}
+
+	attachments, err := v.Attachments(id)
+	if err != nil {
+		return err
+	}
+	for _, file := range attachments {
+		if file.Description == name {
+			return fmt.Errorf("%s already has the attachment %s", id, name)
+		}
+	}
+
+	file := File{
+		ID:          generateID(v.files.Contains),
+		Parent:      id,
+		Type:        TypeBinary,
+		Description: name,
+	}
+	for _, r := range parent.Recipients {
+		file.Recipients = append(file.Recipients, Recipient{Key: r.Key})
+	}
+
+	return v.insert(file, src)
+}
+
+// Attachments возвращает конфигурации вложений элемента с ID id с
+// расшифрованными именами в описании.
+func (v *Vault) Attachments(id string) (Files, error) {
+	if _, err := v.Stat(id); err != nil {
+		return nil, err
+	}
+
+	var files Files
+	for _, file := range v.files {
+		if file.Parent != id || file.IsDeleted {
+			continue
+		}
+		file, err := v.unseal(file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// Attachment возвращает расшифрованное вложение name элемента с ID id.
+func (v *Vault) Attachment(id, name string) (io.ReadCloser, error) {
+	attachments, err := v.Attachments(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range attachments {
+		if file.Description == name {
+			return v.decrypt(file)
+		}
+	}
+	return nil, fmt.Errorf("%s has no attachment %s", id, name)
+}
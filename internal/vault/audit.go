@@ -0,0 +1,134 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/passgen"
+)
+
+// Проблемы, выявляемые проверкой хранилища.
+const (
+	IssueWeak    = "weak"    // Слабый пароль.
+	IssueReused  = "reused"  // Пароль используется в нескольких записях.
+	IssueStale   = "stale"   // Данные давно не обновлялись.
+	IssueExpired = "expired" // Срок действия карты истёк.
+)
+
+// Параметры проверки по умолчанию.
+const (
+	DefaultMinEntropy = 60
+	DefaultMaxAge     = 365 * 24 * time.Hour
+)
+
+// AuditOptions определяет параметры проверки хранилища.
+type AuditOptions struct {
+	Now        time.Time     // Момент проверки.
+	MaxAge     time.Duration // Срок, после которого данные считаются устаревшими; 0 отключает проверку.
+	MinEntropy float64       // Минимальная оценка энтропии пароля в битах.
+}
+
+// Finding определяет проблему, выявленную проверкой хранилища.
+type Finding struct {
+	ID          string `json:"id"`          // ID данных.
+	Type        string `json:"type"`        // Тип данных.
+	Description string `json:"description"` // Описание данных.
+	Issue       string `json:"issue"`       // Проблема.
+	Detail      string `json:"detail"`      // Пояснение.
+}
+
+// Report определяет результат проверки хранилища.
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"` // Момент проверки.
+	Items     int       `json:"items"`      // Количество проверенных данных.
+	Findings  []Finding `json:"findings"`   // Выявленные проблемы.
+}
+
+// Audit расшифровывает данные хранилища и проверяет их: пароли учётных
+// записей на стойкость и повторное использование, срок действия карт и
+// давность последнего изменения всех данных, кроме вложений.
+func (v *Vault) Audit(opts AuditOptions) (Report, error) {
+	files, err := v.List()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		CheckedAt: opts.Now,
+		Items:     len(files),
+		Findings:  []Finding{},
+	}
+
+	add := func(file File, issue, detail string) {
+		report.Findings = append(report.Findings, Finding{
+			ID:          file.ID,
+			Type:        file.Type.String(),
+			Description: file.Description,
+			Issue:       issue,
+			Detail:      detail,
+		})
+	}
+
+	// Повторное использование паролей выявляется по их хешам, чтобы не
+	// хранить сами пароли.
+	reused := make(map[[sha256.Size]byte][]File)
+
+	for _, file := range files {
+		switch file.Type {
+		case TypeLogpass:
+			var up UsernamePassword
+			if err = v.unmarshal(file, &up); err != nil {
+				return Report{}, fmt.Errorf("%s: %w", file.ID, err)
+			}
+			if bits := passgen.Estimate(up.Password); bits < opts.MinEntropy {
+				add(file, IssueWeak, fmt.Sprintf("estimated %.0f bits, at least %.0f expected", bits, opts.MinEntropy))
+			}
+			sum := sha256.Sum256(up.Password)
+			reused[sum] = append(reused[sum], file)
+			up.Wipe()
+
+		case TypeCard:
+			var card BankCard
+			if err = v.unmarshal(file, &card); err != nil {
+				return Report{}, fmt.Errorf("%s: %w", file.ID, err)
+			}
+			if card.Expiry.Expired(opts.Now) {
+				add(file, IssueExpired, "expired in "+card.Expiry.String())
+			}
+			card.Wipe()
+		}
+
+		if age := opts.Now.Sub(file.LastUpdate); opts.MaxAge > 0 && age > opts.MaxAge {
+			add(file, IssueStale, fmt.Sprintf("not updated for %d days", int(age/(24*time.Hour))))
+		}
+	}
+
+	for _, group := range reused {
+		if len(group) < 2 {
+			continue
+		}
+		for _, file := range group {
+			var others []string
+			for _, other := range group {
+				if other.ID != file.ID {
+					others = append(others, other.ID)
+				}
+			}
+			sort.Strings(others)
+			add(file, IssueReused, "same password as "+strings.Join(others, ", "))
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Issue < b.Issue
+	})
+
+	return report, nil
+}
@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+)
+
+// IssueBreached означает, что пароль встречается в известных утечках.
+const IssueBreached = "breached"
+
+// BreachDB определяет базу скомпрометированных паролей.
+type BreachDB interface {
+	// Count возвращает, сколько раз пароль встречался в утечках.
+	Count(password []byte) (int, error)
+}
+
+// CheckBreaches расшифровывает пароли учётных записей и ищет их в базе db.
+// Пароли проверяются только локально и никуда не передаются.
+func (v *Vault) CheckBreaches(db BreachDB) ([]Finding, error) {
+	files, err := v.List()
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+
+	for _, file := range files {
+		if file.Type != TypeLogpass {
+			continue
+		}
+
+		var up UsernamePassword
+		if err = v.unmarshal(file, &up); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.ID, err)
+		}
+		n, err := db.Count(up.Password)
+		up.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.ID, err)
+		}
+
+		if n > 0 {
+			findings = append(findings, Finding{
+				ID:          file.ID,
+				Type:        file.Type.String(),
+				Description: file.Description,
+				Issue:       IssueBreached,
+				Detail:      fmt.Sprintf("seen %d times in breaches", n),
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].ID < findings[j].ID
+	})
+
+	return findings, nil
+}
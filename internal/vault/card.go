@@ -0,0 +1,357 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// Формат записи банковской карты:
+//
+//	card   = version(1) | field(number) | field(holder) | month(1) | year(2) | field(cvv)
+//	field  = len(4) | data
+//
+// Записи, созданные до появления версий, содержат только 16 цифр номера.
+
+const (
+	cardVersion    = 1
+	legacyCardSize = 16
+
+	minCardNumber = 12
+	maxCardNumber = 19
+)
+
+// Платёжные системы банковских карт.
+const (
+	BrandUnknown    = "UNKNOWN"
+	BrandVisa       = "VISA"
+	BrandMastercard = "MASTERCARD"
+	BrandAmex       = "AMEX"
+	BrandMIR        = "MIR"
+	BrandUnionPay   = "UNIONPAY"
+)
+
+// Поля банковской карты.
+const (
+	CardNumber = "number"
+	CardBrand  = "brand"
+	CardHolder = "holder"
+	CardExpiry = "expiry"
+	CardCVV    = "cvv"
+)
+
+// BankCard определяет данные банковской карты. Номер и CVV хранятся в срезах
+// байт, чтобы их можно было очистить при помощи Wipe.
+type BankCard struct {
+	Number []byte // Номер карты, только цифры.
+	Holder string // Имя держателя.
+	Expiry Expiry // Срок действия.
+	CVV    []byte // Код проверки подлинности.
+}
+
+// NewBankCard конвертирует number в BankCard, отбрасывая разделители групп
+// цифр.
+func NewBankCard(number string) BankCard {
+	var c BankCard
+	for i := 0; i < len(number); i++ {
+		if v := number[i]; v >= '0' && v <= '9' {
+			c.Number = append(c.Number, v)
+		}
+	}
+	return c
+}
+
+func (c BankCard) String() string {
+	b, err := c.AppendFormat(nil, Format{})
+	if err != nil {
+		return "<invalid>"
+	}
+	return string(b)
+}
+
+// Validate проверяет длину номера банковской карты и его контрольную сумму
+// по алгоритму Луна, а также срок действия и CVV, если они заданы.
+func (c BankCard) Validate() error {
+	if n := len(c.Number); n < minCardNumber || n > maxCardNumber {
+		return fmt.Errorf("bank card number must have %d to %d digits", minCardNumber, maxCardNumber)
+	}
+	if !luhn(c.Number) {
+		return errors.New("bank card number is invalid")
+	}
+	if err := c.Expiry.Validate(); err != nil {
+		return err
+	}
+	if len(c.CVV) > 0 {
+		size := 3
+		if c.Brand() == BrandAmex {
+			size = 4
+		}
+		if len(c.CVV) != size || !isDigits(c.CVV) {
+			return fmt.Errorf("cvv of %s card must have %d digits", c.Brand(), size)
+		}
+	}
+	return nil
+}
+
+// Brand определяет платёжную систему карты по диапазону IIN.
+func (c BankCard) Brand() string {
+	prefix := func(n int) int {
+		if len(c.Number) < n {
+			return -1
+		}
+		v, _ := strconv.Atoi(string(c.Number[:n]))
+		return v
+	}
+
+	switch p2, p4 := prefix(2), prefix(4); {
+	case p2 == 34 || p2 == 37:
+		return BrandAmex
+	case prefix(1) == 4:
+		return BrandVisa
+	case p4 >= 2200 && p4 <= 2204:
+		return BrandMIR
+	case p2 >= 51 && p2 <= 55, p4 >= 2221 && p4 <= 2720:
+		return BrandMastercard
+	case p2 == 62:
+		return BrandUnionPay
+	default:
+		return BrandUnknown
+	}
+}
+
+// AppendFormat добавляет к b данные карты в виде f: все заданные поля по
+// одному в строке или значение одного поля. Номер карты группируется так
+// же, как на самой карте, а в маскированном виде из него остаются только
+// последние 4 цифры.
+func (c BankCard) AppendFormat(b []byte, f Format) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return b, err
+	}
+
+	number := func(b []byte) []byte {
+		for i, d := range c.Number {
+			if i > 0 && c.groupStart(i) {
+				b = append(b, ' ')
+			}
+			if f.Mask && i < len(c.Number)-4 {
+				d = '*'
+			}
+			b = append(b, d)
+		}
+		return b
+	}
+	cvv := func(b []byte) []byte {
+		if f.Mask {
+			return append(b, bytes.Repeat([]byte("*"), len(c.CVV))...)
+		}
+		return append(b, c.CVV...)
+	}
+
+	switch f.Field {
+	case "":
+	case CardNumber:
+		return number(b), nil
+	case CardBrand:
+		return append(b, c.Brand()...), nil
+	case CardHolder:
+		return append(b, c.Holder...), nil
+	case CardExpiry:
+		if c.Expiry.IsZero() {
+			return b, nil
+		}
+		return append(b, c.Expiry.String()...), nil
+	case CardCVV:
+		return cvv(b), nil
+	default:
+		return b, fmt.Errorf("unknown card field %q, expected one of %s", f.Field,
+			strings.Join([]string{CardNumber, CardBrand, CardHolder, CardExpiry, CardCVV}, ", "))
+	}
+
+	b = append(b, CardNumber+": "...)
+	b = number(b)
+	b = append(b, "\n"+CardBrand+": "+c.Brand()...)
+	if c.Holder != "" {
+		b = append(b, "\n"+CardHolder+": "+c.Holder...)
+	}
+	if !c.Expiry.IsZero() {
+		b = append(b, "\n"+CardExpiry+": "+c.Expiry.String()...)
+	}
+	if len(c.CVV) > 0 {
+		b = append(b, "\n"+CardCVV+": "...)
+		b = cvv(b)
+	}
+
+	return b, nil
+}
+
+// groupStart возвращает true, если с i-й цифры номера начинается новая
+// группа: 4-6-5 у карт American Express и по 4 цифры у остальных.
+func (c BankCard) groupStart(i int) bool {
+	if c.Brand() == BrandAmex && len(c.Number) == 15 {
+		return i == 4 || i == 10
+	}
+	return i%4 == 0
+}
+
+// Wipe заполняет номер карты и CVV нулями.
+func (c *BankCard) Wipe() {
+	secmem.Wipe(c.Number)
+	secmem.Wipe(c.CVV)
+}
+
+func (c BankCard) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 1+len(c.Number)+len(c.Holder)+len(c.CVV)+3*4+3)
+	b = append(b, cardVersion)
+	b = appendField(b, c.Number)
+	b = appendField(b, []byte(c.Holder))
+	b = append(b, byte(c.Expiry.Month))
+	b = binary.BigEndian.AppendUint16(b, uint16(c.Expiry.Year))
+	b = appendField(b, c.CVV)
+	return b, nil
+}
+
+func (c *BankCard) UnmarshalBinary(data []byte) error {
+	if len(data) == legacyCardSize && isDigits(data) {
+		*c = BankCard{Number: bytes.Clone(data)}
+		return nil
+	}
+
+	errCorrupted := errors.New("bank card is corrupted")
+
+	if len(data) == 0 {
+		return errCorrupted
+	}
+	if data[0] != cardVersion {
+		return fmt.Errorf("bank card version %d is not supported", data[0])
+	}
+	data = data[1:]
+
+	var p BankCard
+	var number, holder, cvv []byte
+	var ok bool
+
+	if number, data, ok = readField(data); !ok {
+		return errCorrupted
+	}
+	if holder, data, ok = readField(data); !ok {
+		return errCorrupted
+	}
+	if len(data) < 3 {
+		return errCorrupted
+	}
+	p.Expiry.Month = int(data[0])
+	p.Expiry.Year = int(binary.BigEndian.Uint16(data[1:3]))
+	if cvv, data, ok = readField(data[3:]); !ok || len(data) != 0 {
+		return errCorrupted
+	}
+
+	p.Number = bytes.Clone(number)
+	p.Holder = string(holder)
+	if len(cvv) > 0 {
+		p.CVV = bytes.Clone(cvv)
+	}
+	*c = p
+
+	return nil
+}
+
+// Expiry определяет срок действия банковской карты: месяц и год, до конца
+// которого карта действительна. Нулевое значение означает, что срок не
+// задан.
+type Expiry struct {
+	Month int
+	Year  int
+}
+
+// ParseExpiry разбирает срок действия в виде MM/YY или MM/YYYY.
+func ParseExpiry(s string) (Expiry, error) {
+	errFormat := fmt.Errorf("expiry %q must be MM/YY or MM/YYYY", s)
+
+	month, year, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || len(month) != 2 || (len(year) != 2 && len(year) != 4) {
+		return Expiry{}, errFormat
+	}
+
+	var e Expiry
+	var err error
+
+	if e.Month, err = strconv.Atoi(month); err != nil {
+		return Expiry{}, errFormat
+	}
+	if e.Year, err = strconv.Atoi(year); err != nil {
+		return Expiry{}, errFormat
+	}
+	if len(year) == 2 {
+		e.Year += 2000
+	}
+
+	return e, e.Validate()
+}
+
+// IsZero возвращает true, если срок действия не задан.
+func (e Expiry) IsZero() bool {
+	return e == Expiry{}
+}
+
+// Validate возвращает ошибку, если срок действия задан неверно.
+func (e Expiry) Validate() error {
+	if e.IsZero() {
+		return nil
+	}
+	if e.Month < 1 || e.Month > 12 {
+		return fmt.Errorf("expiry month %d is invalid", e.Month)
+	}
+	if e.Year < 2000 || e.Year > 2099 {
+		return fmt.Errorf("expiry year %d is invalid", e.Year)
+	}
+	return nil
+}
+
+// Expired возвращает true, если срок действия истёк к моменту now.
+func (e Expiry) Expired(now time.Time) bool {
+	if e.IsZero() {
+		return false
+	}
+	end := time.Date(e.Year, time.Month(e.Month)+1, 1, 0, 0, 0, 0, now.Location())
+	return !now.Before(end)
+}
+
+func (e Expiry) String() string {
+	return fmt.Sprintf("%02d/%02d", e.Month, e.Year%100)
+}
+
+// luhn проверяет контрольную сумму номера по алгоритму Луна.
+func luhn(number []byte) bool {
+	var sum int
+	parity := len(number) % 2
+	for i, v := range number {
+		digit := int(v - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if i%2 == parity {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// isDigits возвращает true, если b состоит только из цифр.
+func isDigits(b []byte) bool {
+	for _, v := range b {
+		if v < '0' || v > '9' {
+			return false
+		}
+	}
+	return true
+}
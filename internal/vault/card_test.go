@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBankCard_Brand(t *testing.T) {
+	testCases := []struct {
+		number string
+		brand  string
+		valid  bool
+	}{
+		{"4720 4755 3562 9559", BrandVisa, true},
+		{"4111 1111 1111 1111 003", BrandVisa, true},
+		{"3782 822463 10005", BrandAmex, true},
+		{"5555 5555 5555 4444", BrandMastercard, true},
+		{"2221 0000 0000 0009", BrandMastercard, true},
+		{"2200 1234 5678 9019", BrandMIR, true},
+		{"6200 0000 0000 0005", BrandUnionPay, true},
+		{"9000 0000 0001", BrandUnknown, true},
+		{"4111 111", BrandVisa, false},
+		{"4111 1111 1111 1111 1111", BrandVisa, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.number, func(t *testing.T) {
+			card := NewBankCard(tc.number)
+			require.Equal(t, tc.brand, card.Brand())
+			require.Equal(t, tc.valid, card.Validate() == nil)
+		})
+	}
+}
+
+func TestBankCard_Binary(t *testing.T) {
+	card := NewBankCard("3782 822463 10005")
+	card.Holder = "JOHN DOE"
+	card.Expiry = Expiry{Month: 9, Year: 2027}
+	card.CVV = []byte("1234")
+	require.NoError(t, card.Validate())
+
+	data, err := card.MarshalBinary()
+	require.NoError(t, err)
+
+	var got BankCard
+	require.NoError(t, got.UnmarshalBinary(data))
+	require.Equal(t, card, got)
+
+	require.Error(t, got.UnmarshalBinary(data[:len(data)-1]))
+
+	// Записи прежнего формата содержат только 16 цифр номера.
+	require.NoError(t, got.UnmarshalBinary([]byte("4720475535629559")))
+	require.Equal(t, NewBankCard("4720 4755 3562 9559"), got)
+}
+
+func TestBankCard_AppendFormat(t *testing.T) {
+	card := NewBankCard("3782 822463 10005")
+	card.Holder = "JOHN DOE"
+	card.Expiry = Expiry{Month: 9, Year: 2027}
+	card.CVV = []byte("1234")
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{Format{}, "number: 3782 822463 10005\nbrand: AMEX\nholder: JOHN DOE\nexpiry: 09/27\ncvv: 1234"},
+		{Format{Mask: true}, "number: **** ****** *0005\nbrand: AMEX\nholder: JOHN DOE\nexpiry: 09/27\ncvv: ****"},
+		{Format{Field: CardNumber}, "3782 822463 10005"},
+		{Format{Field: CardCVV, Mask: true}, "****"},
+		{Format{Field: CardExpiry}, "09/27"},
+	}
+
+	for _, tc := range testCases {
+		b, err := card.AppendFormat(nil, tc.format)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(b))
+	}
+
+	_, err := card.AppendFormat(nil, Format{Field: "pin"})
+	require.Error(t, err)
+
+	card.CVV = []byte("123")
+	require.Error(t, card.Validate())
+}
+
+func TestParseExpiry(t *testing.T) {
+	testCases := []struct {
+		text string
+		want Expiry
+		ok   bool
+	}{
+		{"09/27", Expiry{9, 2027}, true},
+		{"12/2030", Expiry{12, 2030}, true},
+		{"13/27", Expiry{}, false},
+		{"9/27", Expiry{}, false},
+		{"09-27", Expiry{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := ParseExpiry(tc.text)
+			if !tc.ok {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	e := Expiry{Month: 2, Year: 2024}
+	require.False(t, e.Expired(time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC)))
+	require.True(t, e.Expired(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+}
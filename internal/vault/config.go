@@ -10,8 +10,14 @@ import (
 )
 
 const (
-	FilesName  = "files"  // Наименование файла для files.
-	RemoteName = "remote" // Наименование файла для remote.
+	FilesName   = "files"   // Наименование файла для files.
+	KeysName    = "keys"    // Наименование файла для keys.
+	RemoteName  = "remote"  // Наименование файла для remote.
+	JournalName = "journal" // Наименование файла для journal.
+	AgentName   = "agent"   // Наименование сокета агента ключей.
+
+	IdentitiesName = "identities" // Наименование файла для identities.
+	SettingsName   = "settings"   // Наименование файла для settings.
 )
 
 type counter struct {
@@ -57,15 +63,97 @@ func (r Remote) WriteTo(dst io.Writer) (int64, error) {
 	return int64(c.n), err
 }
 
+var (
+	_ io.WriterTo   = (*Settings)(nil)
+	_ io.ReaderFrom = (*Settings)(nil)
+)
+
+// Settings определяет настройки хранилища на текущем устройстве.
+type Settings struct {
+	KeyFile       string `json:"key_file,omitempty"`       // Путь к ключевому файлу.
+	HistoryLimit  *int   `json:"history_limit,omitempty"`  // Количество хранимых прежних паролей.
+	RevisionLimit *int   `json:"revision_limit,omitempty"` // Количество хранимых прежних версий данных.
+}
+
+func (s *Settings) ReadFrom(src io.Reader) (int64, error) {
+	c := &counter{Reader: src}
+	err := json.NewDecoder(c).Decode(s)
+	return int64(c.n), err
+}
+
+func (s Settings) WriteTo(dst io.Writer) (int64, error) {
+	c := &counter{Writer: dst}
+	enc := json.NewEncoder(c)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(&s)
+	return int64(c.n), err
+}
+
 // File определяет конфигурацию файла с зашифрованными данными.
+//
+// Тип, описание, размер данных и имя устройства, на котором они изменены,
+// хранятся зашифрованными ключом файла в Sealed, а открыто хранятся только
+// сведения, необходимые для синхронизации.
 type File struct {
-	ID          string       `json:"id"`          // Уникальный идентификатор.
-	Type        Type         `json:"type"`        // Тип зашифрованных данных.
-	Description string       `json:"description"` // Описание данных.
-	SHA256      string       `json:"sha256"`      // Хеш-строка.
-	Meta        cryptio.Meta `json:"meta"`        // Метаданные.
-	LastUpdate  time.Time    `json:"last_update"` // Последнее изменение файла.
-	IsDeleted   bool         `json:"is_deleted"`  // Флаг удаления.
+	ID          string       `json:"id"`                   // Уникальный идентификатор.
+	Parent      string       `json:"parent,omitempty"`     // ID элемента, к которому приложен файл.
+	Revises     string       `json:"revises,omitempty"`    // ID элемента, прежней версией которого является файл.
+	Revision    int          `json:"revision,omitempty"`   // Номер версии данных.
+	Type        Type         `json:"-"`                    // Тип зашифрованных данных.
+	Description string       `json:"-"`                    // Описание данных.
+	Device      string       `json:"-"`                    // Устройство, на котором изменены данные.
+	SHA256      string       `json:"sha256"`               // Хеш-строка.
+	Size        int64        `json:"-"`                    // Размер расшифрованных данных.
+	Meta        cryptio.Meta `json:"meta"`                 // Метаданные.
+	KeyID       string       `json:"key_id"`               // Идентификатор ключа шифрования.
+	Recipients  []Recipient  `json:"recipients,omitempty"` // Получатели.
+	Sealed      []byte       `json:"sealed,omitempty"`     // Зашифрованные сведения о файле.
+	LastUpdate  time.Time    `json:"last_update"`          // Последнее изменение файла.
+	IsDeleted   bool         `json:"is_deleted"`           // Флаг удаления.
+}
+
+// fileInfo определяет сведения о файле, которые хранятся в зашифрованном
+// виде. Файлы, созданные до шифрования конфигурации, хранят их открыто.
+type fileInfo struct {
+	Type        Type   `json:"type,omitempty"`        // Тип зашифрованных данных.
+	Description string `json:"description,omitempty"` // Описание данных.
+	Device      string `json:"device,omitempty"`      // Устройство, на котором изменены данные.
+	Size        int64  `json:"size,omitempty"`        // Размер расшифрованных данных.
+}
+
+func (f File) MarshalJSON() ([]byte, error) {
+	type plain File
+	v := struct {
+		plain
+		fileInfo
+	}{plain: plain(f)}
+	if len(f.Sealed) == 0 {
+		v.fileInfo = fileInfo{Type: f.Type, Description: f.Description}
+	}
+	return json.Marshal(v)
+}
+
+func (f *File) UnmarshalJSON(b []byte) error {
+	type plain File
+	var v struct {
+		plain
+		fileInfo
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = File(v.plain)
+	if len(f.Sealed) == 0 {
+		f.Type, f.Description = v.fileInfo.Type, v.fileInfo.Description
+	}
+	return nil
+}
+
+// Recipient определяет получателя файла: ключ файла, обёрнутый открытым
+// ключом получателя.
+type Recipient struct {
+	Key     string `json:"key"`     // Открытый ключ получателя.
+	Wrapped []byte `json:"wrapped"` // Обёрнутый ключ файла.
 }
 
 // After возвращает true, если дата последнего изменения файла позже чем в x.
@@ -110,6 +198,13 @@ func (fs Files) Lookup(id string) (File, int) {
 	return File{}, -1
 }
 
+// Contains сообщает, есть ли в конфигурации файл с ID id, в том числе
+// удалённый.
+func (fs Files) Contains(id string) bool {
+	_, i := fs.Lookup(id)
+	return i >= 0
+}
+
 // Clone возвращает полную копию Files.
 func (fs Files) Clone() Files {
 	fs2 := make(Files, len(fs))
@@ -117,48 +212,234 @@ func (fs Files) Clone() Files {
 	return fs2
 }
 
-// Merge объединяет конфигурацию файлов в одну и возвращает её.
+// Merge объединяет конфигурацию файлов в одну и возвращает её. В результат
+// попадают файлы обеих конфигураций; из двух версий одного файла выбирается
+// изменённая позже. Вложения и прежние версии удалённых элементов удаляются
+// вместе с ними.
 func (fs Files) Merge(x Files) Files {
-	if len(fs) == 0 {
-		return x.Clone()
-	}
-	if len(x) == 0 {
-		return fs.Clone()
+	merged := make(Files, 0, len(fs)+len(x))
+
+	set := make(map[string]int, len(fs)+len(x))
+	for _, files := range []Files{fs, x} {
+		for _, file := range files {
+			i, ok := set[file.ID]
+			if !ok {
+				set[file.ID] = len(merged)
+				merged = append(merged, file)
+				continue
+			}
+			if file.LastUpdate.After(merged[i].LastUpdate) {
+				merged[i] = file
+			}
+		}
 	}
-	if len(x) < len(fs) {
-		x, fs = fs, x
+
+	live := merged[:0]
+	for _, file := range merged {
+		if !file.IsDeleted {
+			live = append(live, file)
+		}
 	}
+	merged = live.dropOrphans()
 
-	merged := make(Files, 0, len(fs)+len(x))
+	// Файлы с одинаковой датой изменения упорядочиваются по ID, чтобы
+	// результат не зависел от порядка аргументов.
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ID < merged[j].ID })
+	sort.Stable(merged)
 
-	set := make(map[string]int, len(fs))
-	for i, file := range fs {
-		set[file.ID] = i
+	return merged[:len(merged):len(merged)]
+}
+
+// dropOrphans удаляет из fs вложения и прежние версии, элементы которых
+// отсутствуют или удалены.
+func (fs Files) dropOrphans() Files {
+	live := make(map[string]bool, len(fs))
+	for _, file := range fs {
+		if !file.IsDeleted {
+			live[file.ID] = true
+		}
 	}
 
-	for _, file := range x {
-		i, ok := set[file.ID]
-		if !ok {
-			if !file.IsDeleted {
-				merged = append(merged, file)
-			}
-			continue
+	kept := fs[:0]
+	for _, file := range fs {
+		if (file.Parent == "" || live[file.Parent]) && (file.Revises == "" || live[file.Revises]) {
+			kept = append(kept, file)
 		}
+	}
+
+	return kept
+}
+
+// Key определяет ключ шифрования хранилища. Ключ данных хранилища хранится
+// только в обёрнутом виде: он зашифрован ключом, который формируется из
+// мастер-пароля и нигде не хранится. Ключом данных, в свою очередь,
+// обёрнуты собственные ключи файлов.
+type Key struct {
+	ID        string      `json:"id"`         // Уникальный идентификатор.
+	KDF       cryptio.KDF `json:"kdf"`        // Параметры формирования ключа из пароля.
+	Check     []byte      `json:"check"`      // Контрольное значение ключа из пароля.
+	Wrapped   []byte      `json:"wrapped"`    // Обёрнутый ключ данных.
+	KeyFile   bool        `json:"key_file"`   // Ключ из пароля объединён с ключевым файлом.
+	CreatedAt time.Time   `json:"created_at"` // Дата создания ключа.
+	UpdatedAt time.Time   `json:"updated_at"` // Дата последнего изменения ключа.
+}
+
+var (
+	_ sort.Interface = (*Keyring)(nil)
+	_ io.ReaderFrom  = (*Keyring)(nil)
+	_ io.WriterTo    = (*Keyring)(nil)
+)
 
-		matched := fs[i]
+// Keyring определяет набор ключей шифрования хранилища. Основным считается
+// последний созданный ключ; остальные нужны для расшифровки файлов,
+// зашифрованных ранее или на других устройствах.
+type Keyring []Key
 
-		if file.LastUpdate.After(matched.LastUpdate) {
-			matched = file
+func (kr Keyring) Len() int           { return len(kr) }
+func (kr Keyring) Less(i, j int) bool { return kr[i].CreatedAt.After(kr[j].CreatedAt) }
+func (kr Keyring) Swap(i, j int)      { kr[i], kr[j] = kr[j], kr[i] }
+
+func (kr *Keyring) ReadFrom(src io.Reader) (int64, error) {
+	c := &counter{Reader: src}
+	err := json.NewDecoder(c).Decode(kr)
+	return int64(c.n), err
+}
+
+func (kr Keyring) WriteTo(dst io.Writer) (int64, error) {
+	c := &counter{Writer: dst}
+	enc := json.NewEncoder(c)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(kr)
+	return int64(c.n), err
+}
+
+// Lookup выполняет поиск ключа по ID.
+func (kr Keyring) Lookup(id string) (Key, int) {
+	for i, key := range kr {
+		if key.ID == id {
+			return key, i
 		}
-		if !matched.IsDeleted {
-			merged = append(merged, matched)
+	}
+	return Key{}, -1
+}
+
+// Contains сообщает, есть ли в связке ключ с ID id.
+func (kr Keyring) Contains(id string) bool {
+	_, i := kr.Lookup(id)
+	return i >= 0
+}
+
+// Clone возвращает полную копию Keyring.
+func (kr Keyring) Clone() Keyring {
+	kr2 := make(Keyring, len(kr))
+	copy(kr2, kr)
+	return kr2
+}
+
+// Primary возвращает основной ключ хранилища.
+func (kr Keyring) Primary() (Key, bool) {
+	if len(kr) == 0 {
+		return Key{}, false
+	}
+	primary := kr[0]
+	for _, key := range kr[1:] {
+		if key.CreatedAt.After(primary.CreatedAt) {
+			primary = key
 		}
 	}
+	return primary, true
+}
 
-	if len(merged) > 0 {
-		sort.Sort(merged)
-		merged = merged[:len(merged):len(merged)]
+// Merge объединяет наборы ключей в один и возвращает его. Из двух версий
+// одного ключа выбирается изменённая позже.
+func (kr Keyring) Merge(x Keyring) Keyring {
+	merged := make(Keyring, 0, len(kr)+len(x))
+	merged = append(merged, kr...)
+
+	for _, key := range x {
+		matched, i := merged.Lookup(key.ID)
+		if i < 0 {
+			merged = append(merged, key)
+			continue
+		}
+		if key.UpdatedAt.After(matched.UpdatedAt) {
+			merged[i] = key
+		}
 	}
 
-	return merged
+	sort.Stable(merged)
+
+	return merged[:len(merged):len(merged)]
+}
+
+var (
+	_ io.ReaderFrom = (*journal)(nil)
+	_ io.WriterTo   = (*journal)(nil)
+)
+
+// journal определяет журнал перешифрования хранилища: ключ шифрования и
+// конфигурации перешифрованных файлов, которые ещё не зафиксированы. При
+// смене мастер-пароля журнал содержит ключи, обёрнутые новым паролем, и
+// настройки с новым ключевым файлом, которые фиксируются вместе.
+type journal struct {
+	Key      Key       `json:"key"`                // Ключ шифрования.
+	Files    Files     `json:"files"`              // Перешифрованные файлы.
+	Keys     Keyring   `json:"keys,omitempty"`     // Ключи, обёрнутые заново.
+	Settings *Settings `json:"settings,omitempty"` // Настройки хранилища.
+}
+
+func (j *journal) ReadFrom(src io.Reader) (int64, error) {
+	c := &counter{Reader: src}
+	err := json.NewDecoder(c).Decode(j)
+	return int64(c.n), err
+}
+
+func (j journal) WriteTo(dst io.Writer) (int64, error) {
+	c := &counter{Writer: dst}
+	enc := json.NewEncoder(c)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(&j)
+	return int64(c.n), err
+}
+
+var (
+	_ io.ReaderFrom = (*Identities)(nil)
+	_ io.WriterTo   = (*Identities)(nil)
+)
+
+// Identity определяет закрытый ключ получателя, обёрнутый ключом данных
+// хранилища.
+type Identity struct {
+	Recipient string    `json:"recipient"`  // Открытый ключ получателя.
+	KeyID     string    `json:"key_id"`     // Идентификатор ключа шифрования.
+	Wrapped   []byte    `json:"wrapped"`    // Обёрнутый закрытый ключ.
+	CreatedAt time.Time `json:"created_at"` // Дата создания.
+}
+
+// Identities определяет набор закрытых ключей получателя. В отличие от
+// ключей хранилища, они не синхронизируются с удалённым сервером.
+type Identities []Identity
+
+func (ids *Identities) ReadFrom(src io.Reader) (int64, error) {
+	c := &counter{Reader: src}
+	err := json.NewDecoder(c).Decode(ids)
+	return int64(c.n), err
+}
+
+func (ids Identities) WriteTo(dst io.Writer) (int64, error) {
+	c := &counter{Writer: dst}
+	enc := json.NewEncoder(c)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(ids)
+	return int64(c.n), err
+}
+
+// Lookup выполняет поиск закрытого ключа по открытому ключу получателя.
+func (ids Identities) Lookup(recipient string) (Identity, int) {
+	for i, id := range ids {
+		if id.Recipient == recipient {
+			return id, i
+		}
+	}
+	return Identity{}, -1
 }
@@ -179,3 +179,105 @@ func TestFiles_Merge(t *testing.T) {
 		require.Equal(t, tc.fs3, tc.fs1.Merge(tc.fs2))
 	}
 }
+
+func TestFiles_MergeAttachments(t *testing.T) {
+	created := time.Date(2024, 3, 8, 15, 30, 41, 0, time.UTC)
+	deleted := created.Add(time.Minute)
+
+	local := Files{
+		{ID: "1", LastUpdate: created},
+		{ID: "2", Parent: "1", LastUpdate: created},
+		{ID: "3", LastUpdate: created},
+		{ID: "4", Parent: "3", LastUpdate: created},
+	}
+	remote := Files{
+		{ID: "1", LastUpdate: deleted, IsDeleted: true},
+		{ID: "2", Parent: "1", LastUpdate: created},
+		{ID: "3", LastUpdate: created},
+	}
+
+	want := Files{
+		{ID: "3", LastUpdate: created},
+		{ID: "4", Parent: "3", LastUpdate: created},
+	}
+
+	require.Equal(t, want, local.Merge(remote))
+	require.Equal(t, Files{{ID: "3"}}, Files{}.Merge(Files{{ID: "2", Parent: "1"}, {ID: "3"}}))
+}
+
+func TestFiles_MergeRevisions(t *testing.T) {
+	created := time.Date(2024, 3, 8, 15, 30, 41, 0, time.UTC)
+	edited := created.Add(time.Minute)
+	deleted := edited.Add(time.Minute)
+
+	// Ревизия, созданная на одном устройстве, попадает на другое вместе с
+	// новой версией элемента.
+	local := Files{
+		{ID: "1", Revision: 1, LastUpdate: created},
+		{ID: "3", Revision: 1, LastUpdate: created},
+	}
+	remote := Files{
+		{ID: "1", Revision: 2, LastUpdate: edited},
+		{ID: "2", Revises: "1", Revision: 1, LastUpdate: created},
+		{ID: "3", Revision: 1, LastUpdate: created},
+	}
+	require.Equal(t, remote, local.Merge(remote))
+
+	// Ревизии удалённого элемента удаляются вместе с ним.
+	remote[0] = File{ID: "1", Revision: 2, LastUpdate: deleted, IsDeleted: true}
+	require.Equal(t, Files{{ID: "3", Revision: 1, LastUpdate: created}}, local.Merge(remote))
+}
+
+func TestFiles_MergeUnion(t *testing.T) {
+	created := time.Date(2024, 3, 8, 15, 30, 41, 0, time.UTC)
+	edited := created.Add(time.Minute)
+
+	// На устройстве с меньшим числом файлов создана ревизия и вложение,
+	// а на другом — новые элементы.
+	short := Files{
+		{ID: "1", Revision: 2, LastUpdate: edited},
+		{ID: "2", Revises: "1", Revision: 1, LastUpdate: created},
+		{ID: "3", Parent: "1", LastUpdate: created},
+	}
+	long := Files{
+		{ID: "1", Revision: 1, LastUpdate: created},
+		{ID: "4", LastUpdate: created},
+		{ID: "5", LastUpdate: created},
+		{ID: "6", Parent: "4", LastUpdate: created},
+	}
+
+	want := Files{
+		{ID: "1", Revision: 2, LastUpdate: edited},
+		{ID: "2", Revises: "1", Revision: 1, LastUpdate: created},
+		{ID: "3", Parent: "1", LastUpdate: created},
+		{ID: "4", LastUpdate: created},
+		{ID: "5", LastUpdate: created},
+		{ID: "6", Parent: "4", LastUpdate: created},
+	}
+
+	require.Equal(t, want, short.Merge(long))
+	require.Equal(t, want, long.Merge(short))
+	require.Equal(t, Files{{ID: "a"}, {ID: "b"}, {ID: "c"}}, Files{{ID: "a"}, {ID: "b"}}.Merge(Files{{ID: "c"}}))
+}
+
+func TestKeyring_Merge(t *testing.T) {
+	k1 := Key{ID: "1", CreatedAt: time.Date(2024, 3, 8, 15, 30, 41, 0, time.UTC)}
+	k2 := Key{ID: "2", CreatedAt: time.Date(2024, 3, 8, 15, 45, 41, 0, time.UTC)}
+
+	merged := Keyring{k1}.Merge(Keyring{k2, k1})
+	require.Equal(t, Keyring{k2, k1}, merged)
+
+	primary, ok := merged.Primary()
+	require.True(t, ok)
+	require.Equal(t, k2, primary)
+
+	_, ok = Keyring{}.Primary()
+	require.False(t, ok)
+
+	rewrapped := k1
+	rewrapped.UpdatedAt = k2.CreatedAt
+	rewrapped.Wrapped = []byte("rewrapped")
+
+	require.Equal(t, Keyring{k2, rewrapped}, merged.Merge(Keyring{rewrapped}))
+	require.Equal(t, Keyring{k2, rewrapped}, Keyring{rewrapped}.Merge(merged))
+}
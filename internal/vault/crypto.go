@@ -1,42 +1,504 @@
 package vault
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"os"
 
+	"github.com/sergeizaitcev/gophkeeper/internal/agent"
 	"github.com/sergeizaitcev/gophkeeper/pkg/cliutil"
 	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
 	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
 )
 
+// ErrWrongPassword возвращается, когда мастер-пароль не соответствует ключу
+// шифрования хранилища.
+var ErrWrongPassword = errors.New("wrong master password")
+
 // getPass возвращает мастер-пароль пользователя.
-var getpass = func() (string, error) {
+var getpass = func() (*secmem.Buffer, error) {
 	return cliutil.ReadPassword()
 }
 
+// getnewpass возвращает новый мастер-пароль пользователя с подтверждением.
+var getnewpass = func() (*secmem.Buffer, error) {
+	return cliutil.ReadNewPassword()
+}
+
 // decryptCloser определяет реализацию интерфейса io.ReadCloser для
-// cryptio.Decrypter
+// дешифратора.
 type decryptCloser struct {
-	*cryptio.Decrypter
+	io.Reader
 	io.Closer
 }
 
-// generateID генерирует 12-символьную hex-строку.
-func generateID() string {
-	return randutil.Hex(12)
+// idLength определяет длину ID файлов и ключей хранилища.
+const idLength = 12
+
+// generateID генерирует 12-символьную hex-строку, для которой exists
+// возвращает false.
+func generateID(exists func(id string) bool) string {
+	for {
+		id := randutil.Hex(idLength)
+		if !exists(id) {
+			return id
+		}
+	}
 }
 
-func newEncrypter(src io.Reader) (*cryptio.Encrypter, error) {
-	key, err := getpass()
+// password возвращает мастер-пароль пользователя. Пароль запрашивается не
+// более одного раза за время жизни хранилища.
+func (v *Vault) password() ([]byte, error) {
+	if v.pass != nil {
+		return v.pass.Bytes(), nil
+	}
+	pass, err := getpass()
 	if err != nil {
 		return nil, err
 	}
-	return cryptio.NewEncrypter(src, key)
+	v.pass = pass
+	return pass.Bytes(), nil
 }
 
-func newDecrypter(src io.Reader, meta cryptio.Meta) (*cryptio.Decrypter, error) {
-	key, err := getpass()
+// secret возвращает ключ шифрования данных по ID ключа хранилища. Для
+// ключей с обёрнутым ключом данных это ключ данных, для прежних ключей —
+// ключ, сформированный из мастер-пароля; пустой ID соответствует ключу,
+// сформированному устаревшим способом. Если мастер-пароль не соответствует
+// ключу, то возвращается ErrWrongPassword.
+func (v *Vault) secret(id string) ([]byte, error) {
+	if secret, ok := v.secrets[id]; ok {
+		return secret.Bytes(), nil
+	}
+	if secret, err := v.agent().Get(id); err == nil {
+		return v.keep(id, secret), nil
+	}
+
+	if id == "" {
+		pass, err := v.password()
+		if err != nil {
+			return nil, err
+		}
+		secret := cryptio.LegacyKey(pass)
+		if err = v.checkLegacy(secret); err != nil && !errors.Is(err, errLegacyUnverified) {
+			secmem.Wipe(secret)
+			return nil, err
+		}
+		return v.cache(id, secret), nil
+	}
+
+	key, i := v.keys.Lookup(id)
+	if i < 0 {
+		return nil, fmt.Errorf("key %s not found", id)
+	}
+
+	secret, err := v.kek(key)
 	if err != nil {
 		return nil, err
 	}
-	return cryptio.NewDecrypter(src, key, meta)
+
+	if len(key.Wrapped) > 0 {
+		kek := secret
+		secret, err = cryptio.Unwrap(kek, key.Wrapped)
+		secmem.Wipe(kek)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap key %s: %w", id, err)
+		}
+	}
+
+	return v.cache(id, secret), nil
+}
+
+// kek формирует из мастер-пароля и, если требуется, ключевого файла ключ
+// шифрования ключа key и проверяет его по контрольному значению. Ключ
+// следует очистить после использования.
+func (v *Vault) kek(key Key) ([]byte, error) {
+	pass, err := v.password()
+	if err != nil {
+		return nil, err
+	}
+
+	kek := key.KDF.Key(pass)
+
+	if key.KeyFile {
+		digest, err := v.keyFileDigest()
+		if err != nil {
+			return nil, err
+		}
+		if digest == nil {
+			return nil, ErrKeyFileRequired
+		}
+		composite := cryptio.CompositeKey(kek, digest)
+		secmem.Wipe(kek)
+		kek = composite
+	}
+
+	if len(key.Check) > 0 && !hmac.Equal(key.Check, cryptio.Checksum(kek)) {
+		secmem.Wipe(kek)
+		if key.KeyFile {
+			return nil, fmt.Errorf("%w or key file", ErrWrongPassword)
+		}
+		return nil, ErrWrongPassword
+	}
+
+	return kek, nil
+}
+
+// errLegacyUnverified возвращается, когда в хранилище нет файлов, по которым
+// можно проверить ключ, сформированный устаревшим способом.
+var errLegacyUnverified = errors.New("master password cannot be verified against legacy items")
+
+// hasLegacy возвращает true, если хранилище содержит файлы, зашифрованные
+// ключом, сформированным устаревшим способом.
+func (v *Vault) hasLegacy() bool {
+	for _, file := range v.files {
+		if !file.IsDeleted && file.KeyID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLegacy проверяет ключ secret, сформированный устаревшим способом, по
+// файлам хранилища, которые им зашифрованы. Если ключ не соответствует
+// файлам, то возвращается ErrWrongPassword; если проверить ключ не по чему,
+// то возвращается errLegacyUnverified.
+func (v *Vault) checkLegacy(secret []byte) error {
+	for _, file := range v.files {
+		if file.IsDeleted || file.KeyID != "" || isEnveloped(file) {
+			continue
+		}
+		ok, err := v.checkLegacyFile(file, secret)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.ID, err)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return errLegacyUnverified
+}
+
+// checkLegacyFile проверяет ключ secret по файлу file и возвращает true,
+// если проверка состоялась. Поток с аутентификацией проверяется по первому
+// блоку. Файл AES-CTR без аутентификации проверяется только для карт и
+// учётных записей: если зашифрованные данные совпадают с хешем SHA256, а
+// расшифрованные не разбираются, то ключ неверен.
+func (v *Vault) checkLegacyFile(file File, secret []byte) (bool, error) {
+	if !isLegacy(file) {
+		f, err := v.data.Open(file.ID)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+
+		dec, err := cryptio.NewOpener(bufio.NewReader(f), secret)
+		if err == nil {
+			var b [1]byte
+			_, err = dec.Read(b[:])
+		}
+		if errors.Is(err, cryptio.ErrAuthentication) {
+			return false, ErrWrongPassword
+		}
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		return true, nil
+	}
+
+	var value interface {
+		encoding.BinaryUnmarshaler
+		Wipe()
+	}
+	switch file.Type {
+	case TypeCard:
+		value = &BankCard{}
+	case TypeLogpass:
+		value = &UsernamePassword{}
+	default:
+		return false, nil
+	}
+
+	data, err := os.ReadFile(v.data.Path(file.ID))
+	if err != nil {
+		return false, err
+	}
+	defer secmem.Wipe(data)
+
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != file.SHA256 {
+		return false, nil // Повреждённый файл не позволяет проверить ключ.
+	}
+
+	dec, err := cryptio.NewDecrypter(bytes.NewReader(data), secret, file.Meta)
+	if err != nil {
+		return false, err
+	}
+	plain, err := secmem.ReadAll(dec)
+	if err != nil {
+		return false, err
+	}
+	defer plain.Destroy()
+
+	if err = value.UnmarshalBinary(plain.Bytes()); err != nil {
+		return false, ErrWrongPassword
+	}
+	value.Wipe()
+
+	return true, nil
+}
+
+// verify проверяет, что мастер-пароль и ключевой файл соответствуют ключу
+// key.
+func (v *Vault) verify(key Key) error {
+	kek, err := v.kek(key)
+	if err != nil {
+		return err
+	}
+	secmem.Wipe(kek)
+	return nil
+}
+
+// cache сохраняет ключ шифрования на время жизни хранилища, а также в агенте
+// ключей, если он запущен. Возвращает копию ключа в защищённой памяти;
+// secret очищается.
+func (v *Vault) cache(id string, secret []byte) []byte {
+	_ = v.agent().Put(id, secret)
+	return v.keep(id, secret)
+}
+
+// keep сохраняет ключ шифрования в защищённой памяти на время жизни
+// хранилища и возвращает его копию; secret очищается.
+func (v *Vault) keep(id string, secret []byte) []byte {
+	defer secmem.Wipe(secret)
+
+	if old, ok := v.secrets[id]; ok && bytes.Equal(old.Bytes(), secret) {
+		return old.Bytes()
+	}
+	v.secrets[id].Destroy()
+	v.secrets[id] = secmem.Copy(secret)
+
+	return v.secrets[id].Bytes()
+}
+
+// agent возвращает клиент агента ключей.
+func (v *Vault) agent() *agent.Client {
+	return agent.NewClient(v.root.Path(AgentName))
+}
+
+// keyPassword возвращает мастер-пароль для нового ключа шифрования. Если
+// хранилище уже имеет основной ключ, то пароль проверяется по нему, а если
+// содержит файлы, зашифрованные устаревшим способом, — по ним; иначе пароль
+// запрашивается с подтверждением.
+func (v *Vault) keyPassword() ([]byte, error) {
+	if primary, ok := v.keys.Primary(); ok {
+		if err := v.verify(primary); err != nil {
+			return nil, err
+		}
+		return v.pass.Bytes(), nil
+	}
+	if v.hasLegacy() {
+		return v.legacyPassword()
+	}
+	if v.pass != nil {
+		return v.pass.Bytes(), nil
+	}
+	pass, err := getnewpass()
+	if err != nil {
+		return nil, err
+	}
+	v.pass = pass
+	return pass.Bytes(), nil
+}
+
+// legacyPassword возвращает мастер-пароль, которым зашифрованы файлы
+// хранилища устаревшим способом, чтобы новый ключ не был создан другим
+// паролем. Если проверить пароль по файлам нельзя, то он запрашивается
+// повторно.
+func (v *Vault) legacyPassword() ([]byte, error) {
+	pass, err := v.password()
+	if err != nil {
+		return nil, err
+	}
+
+	secret := cryptio.LegacyKey(pass)
+	err = v.checkLegacy(secret)
+	secmem.Wipe(secret)
+
+	if err == nil {
+		return pass, nil
+	}
+	if !errors.Is(err, errLegacyUnverified) {
+		return nil, err
+	}
+
+	repeated, err := getpass()
+	if err != nil {
+		return nil, err
+	}
+	defer repeated.Destroy()
+
+	if !v.pass.Equal(repeated) {
+		return nil, errors.New("passwords do not match")
+	}
+
+	return pass, nil
+}
+
+// primaryKey возвращает основной ключ хранилища. При первом использовании,
+// а также если основной ключ не содержит обёрнутого ключа данных, создаётся
+// новый ключ с прежними параметрами Argon2id.
+func (v *Vault) primaryKey() (Key, error) {
+	key, ok := v.keys.Primary()
+	if ok && len(key.Wrapped) > 0 {
+		return key, nil
+	}
+	return v.NewKey(key.KDF.Time, key.KDF.Memory, key.KDF.Threads)
+}
+
+// Формат зашифрованного файла определяется его метаданными:
+//   - вектор инициализации — устаревший формат AES-CTR без аутентификации;
+//   - пустые метаданные — поток с аутентификацией, зашифрованный ключом
+//     из мастер-пароля;
+//   - обёрнутый ключ — поток с аутентификацией, зашифрованный собственным
+//     ключом файла, который обёрнут ключом данных хранилища.
+
+// isLegacy возвращает true, если файл зашифрован в устаревшем формате
+// AES-CTR без аутентификации.
+func isLegacy(file File) bool {
+	return len(file.Meta) > 0 && !isEnveloped(file)
+}
+
+// isEnveloped возвращает true, если файл зашифрован собственным ключом.
+func isEnveloped(file File) bool {
+	return len(file.Meta) == cryptio.WrappedSize
+}
+
+// newEncrypter возвращает шифратор собственным ключом файла и конфигурацию
+// файла, в которой ключ файла обёрнут ключом key и открытыми ключами
+// получателей. Сведения о файле шифруются после записи данных, когда
+// известен их размер.
+func (v *Vault) newEncrypter(src io.Reader, file File, key Key) (*cryptio.Sealer, File, error) {
+	secret, err := v.secret(key.ID)
+	if err != nil {
+		return nil, File{}, err
+	}
+
+	dek, err := cryptio.NewKey()
+	if err != nil {
+		return nil, File{}, err
+	}
+	defer secmem.Wipe(dek)
+
+	if file.Meta, err = cryptio.Wrap(secret, dek); err != nil {
+		return nil, File{}, err
+	}
+
+	recipients := make([]Recipient, len(file.Recipients))
+	for i, r := range file.Recipients {
+		recipient, err := cryptio.ParseRecipient(r.Key)
+		if err != nil {
+			return nil, File{}, err
+		}
+		recipients[i].Key = r.Key
+		if recipients[i].Wrapped, err = recipient.Wrap(dek); err != nil {
+			return nil, File{}, err
+		}
+	}
+	if len(recipients) > 0 {
+		file.Recipients = recipients
+	}
+
+	enc, err := cryptio.NewSealer(src, dek)
+	if err != nil {
+		return nil, File{}, err
+	}
+
+	return enc, file, nil
+}
+
+// newDecrypter возвращает дешифратор для file.
+func (v *Vault) newDecrypter(src io.Reader, file File) (io.Reader, error) {
+	key, err := v.fileKey(file)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(key)
+
+	if isLegacy(file) {
+		return cryptio.NewDecrypter(src, key, file.Meta)
+	}
+	return cryptio.NewOpener(src, key)
+}
+
+// fileKey возвращает копию ключа, которым зашифрован file. Если file
+// адресован одному из закрытых ключей хранилища, то ключ файла
+// расшифровывается им. Ключ следует очистить после использования.
+func (v *Vault) fileKey(file File) ([]byte, error) {
+	if dek, ok, err := v.recipientKey(file); ok {
+		return dek, err
+	}
+
+	secret, err := v.secret(file.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !isEnveloped(file) {
+		return bytes.Clone(secret), nil
+	}
+
+	dek, err := cryptio.Unwrap(secret, file.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key of %s: %w", file.ID, err)
+	}
+
+	return dek, nil
+}
+
+// seal шифрует тип, описание, устройство и размер file ключом файла.
+func seal(key []byte, file File) ([]byte, error) {
+	b, err := json.Marshal(fileInfo{
+		Type:        file.Type,
+		Description: file.Description,
+		Device:      file.Device,
+		Size:        file.Size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cryptio.Encrypt(key, b, []byte(file.ID))
+}
+
+// unseal расшифровывает тип, описание, устройство и размер file.
+func (v *Vault) unseal(file File) (File, error) {
+	if len(file.Sealed) == 0 {
+		return file, nil
+	}
+
+	key, err := v.fileKey(file)
+	if err != nil {
+		return File{}, err
+	}
+	defer secmem.Wipe(key)
+
+	b, err := cryptio.Decrypt(key, file.Sealed, []byte(file.ID))
+	if err != nil {
+		return File{}, fmt.Errorf("decrypt %s: %w", file.ID, err)
+	}
+
+	var info fileInfo
+	if err = json.Unmarshal(b, &info); err != nil {
+		return File{}, err
+	}
+
+	file.Type, file.Description, file.Device, file.Size = info.Type, info.Description, info.Device, info.Size
+
+	return file, nil
 }
@@ -0,0 +1,118 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// statType возвращает конфигурацию файла с ID id, если его данные имеют тип
+// typ.
+func (v *Vault) statType(id string, typ Type) (File, error) {
+	file, err := v.Stat(id)
+	if err != nil {
+		return File{}, err
+	}
+	if file.Type != typ {
+		return File{}, fmt.Errorf("%s is %s, not %s", id, file.Type, typ)
+	}
+	return file, nil
+}
+
+// BankCard возвращает расшифрованные данные банковской карты с ID id. Номер
+// и CVV следует очистить при помощи Wipe после использования.
+func (v *Vault) BankCard(id string) (BankCard, error) {
+	file, err := v.statType(id, TypeCard)
+	if err != nil {
+		return BankCard{}, err
+	}
+
+	var card BankCard
+	if err = v.unmarshal(file, &card); err != nil {
+		return BankCard{}, err
+	}
+
+	return card, nil
+}
+
+// UpdateBankCard заменяет данные банковской карты с ID id на card, а если
+// description не nil, то и описание.
+func (v *Vault) UpdateBankCard(id string, description *string, card BankCard) error {
+	if _, err := v.statType(id, TypeCard); err != nil {
+		return err
+	}
+	if err := card.Validate(); err != nil {
+		return err
+	}
+	data, err := card.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+	return v.Edit(id, description, bytes.NewReader(data))
+}
+
+// UpdateEnv заменяет набор переменных окружения с ID id на env, а если
+// description не nil, то и описание.
+func (v *Vault) UpdateEnv(id string, description *string, env Env) error {
+	if _, err := v.statType(id, TypeEnv); err != nil {
+		return err
+	}
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	data, err := env.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+	return v.Edit(id, description, bytes.NewReader(data))
+}
+
+// OTPKey возвращает расшифрованный ключ одноразовых паролей с ID id.
+// Секрет следует очистить после использования.
+func (v *Vault) OTPKey(id string) (otp.Key, error) {
+	file, err := v.statType(id, TypeTOTP)
+	if err != nil {
+		return otp.Key{}, err
+	}
+
+	var key otpKey
+	if err = v.unmarshal(file, &key); err != nil {
+		return otp.Key{}, err
+	}
+
+	return key.Key, nil
+}
+
+// UpdateOTP заменяет ключ одноразовых паролей с ID id на key, а если
+// description не nil, то и описание.
+func (v *Vault) UpdateOTP(id string, description *string, key otp.Key) error {
+	if _, err := v.statType(id, TypeTOTP); err != nil {
+		return err
+	}
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	data, err := otpKey{key}.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+	return v.Edit(id, description, bytes.NewReader(data))
+}
+
+// UpdateNote заменяет текстовую заметку с ID id на note, а если
+// description не nil, то и описание.
+func (v *Vault) UpdateNote(id string, description *string, note []byte) error {
+	if _, err := v.statType(id, TypeNote); err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(note)) == 0 {
+		return errors.New("note must not be blank")
+	}
+	return v.Edit(id, description, bytes.NewReader(note))
+}
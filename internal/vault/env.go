@@ -0,0 +1,343 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// Формат записи переменных окружения:
+//
+//	env      = version(1) | count(2) | variable*count
+//	variable = field(name) | field(value)
+//	field    = len(4) | data
+
+const envVersion = 1
+
+// EnvVar определяет переменную окружения. Значение хранится в срезе байт,
+// чтобы его можно было очистить при помощи Wipe.
+type EnvVar struct {
+	Name  string
+	Value []byte
+}
+
+// Env определяет набор переменных окружения.
+type Env []EnvVar
+
+// ParseEnv разбирает переменные окружения в формате .env: по одной
+// переменной KEY=VALUE в строке. Пустые строки, комментарии # и префикс
+// export пропускаются; значение может быть заключено в одинарные кавычки
+// или в двойные, внутри которых поддерживаются \n, \r, \t, \" и \\.
+func ParseEnv(data []byte) (Env, error) {
+	var env Env
+
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		line = bytes.TrimPrefix(line, []byte("export "))
+
+		name, value, ok := bytes.Cut(line, []byte("="))
+		if !ok {
+			env.Wipe()
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n+1)
+		}
+
+		v := EnvVar{Name: string(bytes.TrimSpace(name))}
+
+		var err error
+		if v.Value, err = parseEnvValue(bytes.TrimSpace(value)); err != nil {
+			env.Wipe()
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		if _, ok := env.Lookup(v.Name); ok {
+			env.Wipe()
+			secmem.Wipe(v.Value)
+			return nil, fmt.Errorf("line %d: variable %s is repeated", n+1, v.Name)
+		}
+
+		env = append(env, v)
+	}
+
+	if err := env.Validate(); err != nil {
+		env.Wipe()
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// parseEnvValue возвращает копию значения переменной без кавычек и
+// комментария.
+func parseEnvValue(s []byte) ([]byte, error) {
+	if len(s) == 0 {
+		return []byte{}, nil
+	}
+
+	rest := func(s []byte) error {
+		s = bytes.TrimSpace(s)
+		if len(s) > 0 && s[0] != '#' {
+			return errors.New("unexpected characters after the closing quote")
+		}
+		return nil
+	}
+
+	switch s[0] {
+	case '\'':
+		end := bytes.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, errors.New("unterminated single quote")
+		}
+		if err := rest(s[end+2:]); err != nil {
+			return nil, err
+		}
+		return bytes.Clone(s[1 : end+1]), nil
+
+	case '"':
+		value := make([]byte, 0, len(s))
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '"':
+				if err := rest(s[i+1:]); err != nil {
+					secmem.Wipe(value)
+					return nil, err
+				}
+				return value, nil
+			case c == '\\' && i+1 < len(s):
+				i++
+				switch s[i] {
+				case 'n':
+					value = append(value, '\n')
+				case 'r':
+					value = append(value, '\r')
+				case 't':
+					value = append(value, '\t')
+				default:
+					value = append(value, s[i])
+				}
+			default:
+				value = append(value, c)
+			}
+		}
+		secmem.Wipe(value)
+		return nil, errors.New("unterminated double quote")
+
+	default:
+		if i := bytes.Index(s, []byte(" #")); i >= 0 {
+			s = bytes.TrimSpace(s[:i])
+		}
+		return bytes.Clone(s), nil
+	}
+}
+
+// Lookup возвращает значение переменной с именем name.
+func (e Env) Lookup(name string) ([]byte, bool) {
+	for _, v := range e {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Environ добавляет к environ переменные в виде KEY=VALUE.
+func (e Env) Environ(environ []string) []string {
+	for _, v := range e {
+		environ = append(environ, v.Name+"="+string(v.Value))
+	}
+	return environ
+}
+
+// Validate возвращает ошибку, если набор переменных пуст или содержит
+// недопустимые имена или значения.
+func (e Env) Validate() error {
+	if len(e) == 0 {
+		return errors.New("environment must have at least one variable")
+	}
+
+	names := make(map[string]bool, len(e))
+	for _, v := range e {
+		if !isEnvName(v.Name) {
+			return fmt.Errorf("variable name %q must consist of letters, digits and _ and not start with a digit", v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("variable %s is repeated", v.Name)
+		}
+		if bytes.IndexByte(v.Value, 0) >= 0 {
+			return fmt.Errorf("variable %s must not contain NUL", v.Name)
+		}
+		names[v.Name] = true
+	}
+
+	return nil
+}
+
+// AppendFormat добавляет к b переменные в виде f: все переменные в формате
+// .env или значение одной переменной. Значения с пробелами, кавычками и
+// переводами строк заключаются в двойные кавычки.
+func (e Env) AppendFormat(b []byte, f Format) ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return b, err
+	}
+
+	value := func(b, value []byte) []byte {
+		if f.Mask {
+			return append(b, bytes.Repeat([]byte("*"), len(value))...)
+		}
+		return append(b, value...)
+	}
+
+	if f.Field != "" {
+		v, ok := e.Lookup(f.Field)
+		if !ok {
+			names := make([]string, len(e))
+			for i, v := range e {
+				names[i] = v.Name
+			}
+			return b, fmt.Errorf("unknown variable %q, expected one of %s", f.Field, strings.Join(names, ", "))
+		}
+		return value(b, v), nil
+	}
+
+	for i, v := range e {
+		if i > 0 {
+			b = append(b, '\n')
+		}
+		b = append(b, v.Name+"="...)
+		if f.Mask || !bytes.ContainsAny(v.Value, " \t\r\n\"'#\\") {
+			b = value(b, v.Value)
+			continue
+		}
+		b = append(b, '"')
+		for _, c := range v.Value {
+			switch c {
+			case '\n':
+				b = append(b, `\n`...)
+			case '\r':
+				b = append(b, `\r`...)
+			case '\t':
+				b = append(b, `\t`...)
+			case '"', '\\':
+				b = append(b, '\\', c)
+			default:
+				b = append(b, c)
+			}
+		}
+		b = append(b, '"')
+	}
+
+	return b, nil
+}
+
+// Wipe заполняет значения переменных нулями.
+func (e *Env) Wipe() {
+	for _, v := range *e {
+		secmem.Wipe(v.Value)
+	}
+}
+
+func (e Env) MarshalBinary() ([]byte, error) {
+	if len(e) > 0xffff {
+		return nil, errors.New("environment has too many variables")
+	}
+
+	size := 1 + 2
+	for _, v := range e {
+		size += 4 + len(v.Name) + 4 + len(v.Value)
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, envVersion)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e)))
+	for _, v := range e {
+		b = appendField(b, []byte(v.Name))
+		b = appendField(b, v.Value)
+	}
+
+	return b, nil
+}
+
+func (e *Env) UnmarshalBinary(data []byte) error {
+	errCorrupted := errors.New("environment is corrupted")
+
+	if len(data) == 0 {
+		return errCorrupted
+	}
+	if data[0] != envVersion {
+		return fmt.Errorf("environment version %d is not supported", data[0])
+	}
+
+	n, data, ok := readCount(data[1:])
+	if !ok {
+		return errCorrupted
+	}
+
+	p := make(Env, 0, n)
+	for i := 0; i < n; i++ {
+		var name, value []byte
+		if name, data, ok = readField(data); !ok {
+			return errCorrupted
+		}
+		if value, data, ok = readField(data); !ok {
+			return errCorrupted
+		}
+		p = append(p, EnvVar{Name: string(name), Value: bytes.Clone(value)})
+	}
+	if len(data) != 0 {
+		return errCorrupted
+	}
+
+	*e = p
+
+	return nil
+}
+
+// isEnvName возвращает true, если s является допустимым именем переменной
+// окружения.
+func isEnvName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' && (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// AddEnv добавляет в хранилище зашифрованный набор переменных окружения.
+func (v *Vault) AddEnv(description string, env Env, recipients ...*cryptio.Recipient) error {
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	data, err := env.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+	return v.add(description, TypeEnv, bytes.NewReader(data), recipients)
+}
+
+// Env возвращает расшифрованный набор переменных окружения с ID id. Значения
+// переменных следует очистить при помощи Wipe после использования.
+func (v *Vault) Env(id string) (Env, error) {
+	file, err := v.statType(id, TypeEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	var env Env
+	if err = v.unmarshal(file, &env); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
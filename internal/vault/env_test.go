@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnv(t *testing.T) {
+	data := []byte(`# database
+export DB_HOST=db.internal
+DB_PASSWORD = "p@ss \"word\"\n" # comment
+TOKEN='a#b c'
+EMPTY=
+URL=https://example.com/?a=b # trailing
+`)
+
+	env, err := ParseEnv(data)
+	require.NoError(t, err)
+	require.Equal(t, Env{
+		{Name: "DB_HOST", Value: []byte("db.internal")},
+		{Name: "DB_PASSWORD", Value: []byte("p@ss \"word\"\n")},
+		{Name: "TOKEN", Value: []byte("a#b c")},
+		{Name: "EMPTY", Value: []byte{}},
+		{Name: "URL", Value: []byte("https://example.com/?a=b")},
+	}, env)
+
+	for _, data := range []string{
+		"",
+		"KEY",
+		"1KEY=value",
+		"MY-KEY=value",
+		`KEY="value`,
+		`KEY='value' tail`,
+		"KEY=a\nKEY=b",
+	} {
+		_, err = ParseEnv([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestEnv_AppendFormat(t *testing.T) {
+	env := Env{
+		{Name: "DB_HOST", Value: []byte("db.internal")},
+		{Name: "DB_PASSWORD", Value: []byte("p@ss \"word\"\n")},
+	}
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{Format{}, "DB_HOST=db.internal\nDB_PASSWORD=\"p@ss \\\"word\\\"\\n\""},
+		{Format{Mask: true}, "DB_HOST=***********\nDB_PASSWORD=************"},
+		{Format{Field: "DB_PASSWORD"}, "p@ss \"word\"\n"},
+	}
+
+	for _, tc := range testCases {
+		b, err := env.AppendFormat(nil, tc.format)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(b))
+	}
+
+	_, err := env.AppendFormat(nil, Format{Field: "DB_USER"})
+	require.Error(t, err)
+
+	// Вывод без маскирования разбирается обратно в тот же набор.
+	b, err := env.AppendFormat(nil, Format{})
+	require.NoError(t, err)
+
+	parsed, err := ParseEnv(b)
+	require.NoError(t, err)
+	require.Equal(t, env, parsed)
+}
+
+func TestEnv_Binary(t *testing.T) {
+	env := Env{
+		{Name: "DB_HOST", Value: []byte("db.internal")},
+		{Name: "EMPTY", Value: []byte{}},
+	}
+
+	data, err := env.MarshalBinary()
+	require.NoError(t, err)
+
+	var got Env
+	require.NoError(t, got.UnmarshalBinary(data))
+	require.Equal(t, env, got)
+
+	require.Error(t, got.UnmarshalBinary(data[:len(data)-1]))
+}
@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// DefaultHistoryLimit определяет количество прежних паролей учётной записи,
+// хранимых по умолчанию.
+const DefaultHistoryLimit = 10
+
+// HistoryLimit возвращает количество прежних паролей, хранимых в учётных
+// записях при изменении пароля на текущем устройстве.
+func (v *Vault) HistoryLimit() int {
+	if v.settings.HistoryLimit == nil {
+		return DefaultHistoryLimit
+	}
+	return *v.settings.HistoryLimit
+}
+
+// SetHistoryLimit задаёт количество прежних паролей, хранимых в учётных
+// записях; 0 отключает историю. Лишние пароли удаляются при следующем
+// изменении учётной записи.
+func (v *Vault) SetHistoryLimit(n int) error {
+	if n < 0 || n > 0xffff {
+		return fmt.Errorf("history limit must be between 0 and %d", 0xffff)
+	}
+
+	settings := v.settings
+	settings.HistoryLimit = &n
+	if err := v.save(SettingsName, settings); err != nil {
+		return err
+	}
+	v.settings = settings
+
+	return nil
+}
+
+// Login возвращает расшифрованные данные для авторизации с ID id. Пароли
+// следует очистить при помощи Wipe после использования.
+func (v *Vault) Login(id string) (UsernamePassword, error) {
+	file, err := v.statType(id, TypeLogpass)
+	if err != nil {
+		return UsernamePassword{}, err
+	}
+
+	var up UsernamePassword
+	if err = v.unmarshal(file, &up); err != nil {
+		return UsernamePassword{}, err
+	}
+
+	return up, nil
+}
+
+// UpdateLogin заменяет данные для авторизации с ID id на up, а если
+// description не nil, то и описание. История паролей берётся из хранилища,
+// а не из up: если пароль изменился, то прежний пароль добавляется в её
+// начало с моментом now, после чего история сокращается до HistoryLimit.
+func (v *Vault) UpdateLogin(id string, description *string, up UsernamePassword, now time.Time) error {
+	if err := up.Validate(); err != nil {
+		return err
+	}
+
+	old, err := v.Login(id)
+	if err != nil {
+		return err
+	}
+	defer old.Wipe()
+
+	up.History = old.History
+	if !bytes.Equal(old.Password, up.Password) {
+		prev := PreviousPassword{Password: old.Password, ChangedAt: now.UTC()}
+		up.History = append([]PreviousPassword{prev}, up.History...)
+	}
+	if limit := v.HistoryLimit(); len(up.History) > limit {
+		for _, prev := range up.History[limit:] {
+			secmem.Wipe(prev.Password)
+		}
+		up.History = up.History[:limit]
+	}
+
+	data, err := up.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+
+	return v.Edit(id, description, bytes.NewReader(data))
+}
+
+// ChangeLoginPassword заменяет пароль учётной записи с ID id, сохраняя
+// прежний в истории.
+func (v *Vault) ChangeLoginPassword(id string, password []byte, now time.Time) error {
+	if len(password) == 0 {
+		return errors.New("password must not be blank")
+	}
+
+	up, err := v.Login(id)
+	if err != nil {
+		return err
+	}
+	defer up.Wipe()
+
+	if bytes.Equal(up.Password, password) {
+		return errors.New("the new password is the same as the current one")
+	}
+
+	secmem.Wipe(up.Password)
+	up.Password = bytes.Clone(password)
+
+	return v.UpdateLogin(id, nil, up, now)
+}
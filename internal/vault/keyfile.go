@@ -0,0 +1,122 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+	"github.com/sergeizaitcev/gophkeeper/pkg/workdir"
+)
+
+// ErrKeyFileRequired возвращается, когда ключ хранилища объединён с ключевым
+// файлом, а ключевой файл на текущем устройстве не задан.
+var ErrKeyFileRequired = errors.New("the vault requires a key file")
+
+// Init инициализирует хранилище на текущем устройстве.
+//
+// Если хранилище ещё не имеет ключей, то создаётся основной ключ, который
+// при непустом keyfile объединяется с содержимым ключевого файла; если файла
+// не существует, то он создаётся со случайным содержимым. Если ключи уже
+// получены, например, синхронизацией с другого устройства, то запоминается
+// путь к ключевому файлу, которым они защищены.
+func (v *Vault) Init(keyfile string) error {
+	primary, ok := v.keys.Primary()
+	if ok && keyfile == "" {
+		return errors.New("the vault has already been initialized")
+	}
+	if ok && !primary.KeyFile {
+		return errors.New("the vault keys do not require a key file")
+	}
+
+	settings := v.settings
+	if keyfile != "" {
+		path, digest, err := loadKeyFile(keyfile, !ok)
+		if err != nil {
+			return err
+		}
+		settings.KeyFile = path
+		v.keyfile = digest
+	}
+
+	var err error
+	if ok {
+		err = v.verify(primary)
+	} else {
+		_, err = v.NewKey(0, 0, 0)
+	}
+	if err != nil {
+		v.keyfile = nil
+		return err
+	}
+
+	v.settings = settings
+
+	return v.save(SettingsName, v.settings)
+}
+
+// keyFileDigest возвращает хеш ключевого файла, заданного на текущем
+// устройстве, или nil, если ключевой файл не задан.
+func (v *Vault) keyFileDigest() ([]byte, error) {
+	if v.keyfile != nil || v.settings.KeyFile == "" {
+		return v.keyfile, nil
+	}
+
+	_, digest, err := loadKeyFile(v.settings.KeyFile, false)
+	if err != nil {
+		return nil, err
+	}
+	v.keyfile = digest
+
+	return digest, nil
+}
+
+// loadKeyFile возвращает абсолютный путь к ключевому файлу и хеш его
+// содержимого. Если create равен true, то отсутствующий файл создаётся со
+// случайным содержимым.
+func loadKeyFile(path string, create bool) (string, []byte, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		if err = createKeyFile(path); err != nil {
+			return "", nil, err
+		}
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("open key file: %w", err)
+	}
+	defer f.Close()
+
+	digest, err := cryptio.KeyFileDigest(f)
+	if err != nil {
+		return "", nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	return path, digest, nil
+}
+
+// createKeyFile создаёт ключевой файл со случайным содержимым.
+func createKeyFile(path string) error {
+	b, err := cryptio.NewKeyFile()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, workdir.FileMode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
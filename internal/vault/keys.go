@@ -0,0 +1,465 @@
+package vault
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+	"github.com/sergeizaitcev/gophkeeper/pkg/workdir"
+)
+
+// pendingExt определяет расширение перешифрованного, но ещё не
+// зафиксированного файла.
+const pendingExt = ".pending"
+
+// NewKey создает новый основной ключ хранилища с заданными параметрами
+// Argon2id; нулевые параметры заменяются значениями по умолчанию. Файлы,
+// зашифрованные прежними ключами, остаются доступными и могут быть
+// перешифрованы при помощи Migrate.
+func (v *Vault) NewKey(iterations, memory uint32, threads uint8) (Key, error) {
+	kdf, err := cryptio.NewKDF(iterations, memory, threads)
+	if err != nil {
+		return Key{}, err
+	}
+
+	pass, err := v.keyPassword()
+	if err != nil {
+		return Key{}, err
+	}
+
+	key, err := v.newKey(kdf, pass)
+	if err != nil {
+		return Key{}, err
+	}
+
+	v.keys = v.keys.Merge(Keyring{key})
+
+	return key, v.save(KeysName, v.keys)
+}
+
+// newKey генерирует случайный ключ данных хранилища, оборачивает его ключом,
+// сформированным из пароля, и возвращает результат.
+func (v *Vault) newKey(kdf cryptio.KDF, pass []byte) (Key, error) {
+	secret, err := cryptio.NewKey()
+	if err != nil {
+		return Key{}, err
+	}
+
+	now := time.Now().UTC()
+	key := Key{
+		ID:        generateID(v.keys.Contains),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	keyfile, err := v.keyFileDigest()
+	if err != nil {
+		return Key{}, err
+	}
+
+	if key, err = wrapKey(key, kdf, pass, keyfile, secret); err != nil {
+		secmem.Wipe(secret)
+		return Key{}, err
+	}
+
+	v.cache(key.ID, secret)
+
+	return key, nil
+}
+
+// wrapKey оборачивает ключ данных secret ключом, сформированным из пароля
+// с параметрами kdf и хеша ключевого файла keyfile, если он не равен nil, и
+// возвращает обновлённый ключ хранилища.
+func wrapKey(key Key, kdf cryptio.KDF, pass, keyfile, secret []byte) (Key, error) {
+	kek := kdf.Key(pass)
+	if keyfile != nil {
+		composite := cryptio.CompositeKey(kek, keyfile)
+		secmem.Wipe(kek)
+		kek = composite
+	}
+	defer secmem.Wipe(kek)
+
+	wrapped, err := cryptio.Wrap(kek, secret)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key.KDF = kdf
+	key.Check = cryptio.Checksum(kek)
+	key.Wrapped = wrapped
+	key.KeyFile = keyfile != nil
+
+	return key, nil
+}
+
+// Migrate перешифровывает основным ключом хранилища файлы, зашифрованные
+// устаревшим способом, без собственного ключа, с открытыми типом и
+// описанием или прежними ключами, и возвращает их количество.
+//
+// Файлы AES-CTR без аутентификации, расшифрованные неверным паролем, были
+// бы необратимо испорчены, поэтому перед перешифрованием пароль
+// проверяется по файлам хранилища. Если проверить его нельзя, то копии
+// таких файлов сохраняются в директории LegacyDirName.
+func (v *Vault) Migrate() (int, error) {
+	if v.hasLegacy() {
+		if err := v.keepLegacy(); err != nil {
+			return 0, err
+		}
+	}
+
+	primary, err := v.primaryKey()
+	if err != nil {
+		return 0, err
+	}
+	return v.rekey(primary, func(file File) bool {
+		return file.KeyID != primary.ID || !isEnveloped(file) || len(file.Sealed) == 0
+	})
+}
+
+// keepLegacy проверяет мастер-пароль по файлам, зашифрованным устаревшим
+// способом, и, если проверить его нельзя, сохраняет копии файлов AES-CTR
+// без аутентификации.
+func (v *Vault) keepLegacy() error {
+	secret, err := v.secret("")
+	if err != nil {
+		return err
+	}
+	if err = v.checkLegacy(secret); !errors.Is(err, errLegacyUnverified) {
+		return err
+	}
+
+	dir, err := v.root.Dir(LegacyDirName)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range v.files {
+		if file.IsDeleted || file.KeyID != "" || !isLegacy(file) || dir.Exists(file.ID) {
+			continue
+		}
+		if err = copyFile(dir, v.data, file.ID); err != nil {
+			return fmt.Errorf("keep %s: %w", file.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// copyFile копирует файл name из директории src в директорию dst.
+func copyFile(dst, src workdir.Dir, name string) error {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	temp, err := dst.Temp("temp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.RemoveAll(temp.Name())
+	}()
+
+	if _, err = io.Copy(temp, in); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), dst.Path(name))
+}
+
+// LegacyCopies возвращает путь к директории с копиями файлов, которые
+// сохранил Migrate, или пустую строку, если копий нет.
+func (v *Vault) LegacyCopies() string {
+	if !v.root.Exists(LegacyDirName) {
+		return ""
+	}
+	return v.root.Path(LegacyDirName)
+}
+
+// Rotate перешифровывает файлы с заданными ID новыми собственными ключами.
+func (v *Vault) Rotate(ids ...string) (int, error) {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		file, i := v.files.Lookup(id)
+		if i < 0 {
+			return 0, fmt.Errorf("%s not found", id)
+		}
+		if file.IsDeleted {
+			return 0, fmt.Errorf("%s has been deleted", id)
+		}
+		set[id] = true
+	}
+
+	primary, err := v.primaryKey()
+	if err != nil {
+		return 0, err
+	}
+
+	return v.rekey(primary, func(file File) bool { return set[file.ID] })
+}
+
+// ChangePassword меняет мастер-пароль хранилища и возвращает количество
+// перешифрованных файлов.
+//
+// Ключи данных хранилища, доступные по текущему паролю, обёртываются заново
+// ключом из нового пароля, поэтому сами файлы не перешифровываются.
+// Исключение составляют файлы, зашифрованные непосредственно ключом из
+// пароля: предварительно они перешифровываются основным ключом.
+//
+// Если keyfile не равен nil, то вместе с паролем меняется ключевой файл:
+// пустой путь отменяет его использование, а несуществующий файл создаётся
+// со случайным содержимым.
+func (v *Vault) ChangePassword(keyfile *string) (int, error) {
+	if _, ok := v.keys.Primary(); !ok {
+		return 0, errors.New("master password is not set")
+	}
+
+	primary, err := v.primaryKey()
+	if err != nil {
+		return 0, err
+	}
+	if err = v.verify(primary); err != nil {
+		return 0, err
+	}
+
+	ids := map[string]bool{"": true}
+	for _, key := range v.keys {
+		err := v.verify(key)
+		if errors.Is(err, ErrWrongPassword) || errors.Is(err, ErrKeyFileRequired) {
+			continue // Ключ другого пароля, например, с другого устройства.
+		}
+		if err != nil {
+			return 0, err
+		}
+		if _, err = v.secret(key.ID); err != nil {
+			return 0, err
+		}
+		ids[key.ID] = true
+	}
+
+	return v.resetPassword(primary, ids, keyfile, v.pass)
+}
+
+// resetPassword перешифровывает основным ключом primary файлы, зашифрованные
+// непосредственно ключом из пароля, запрашивает новый мастер-пароль и
+// обёртывает им ключи данных. Затрагиваются только ключи с ID из ids, ключи
+// шифрования которых доступны; current — текущий мастер-пароль, если он
+// известен.
+func (v *Vault) resetPassword(primary Key, ids map[string]bool, keyfile *string, current *secmem.Buffer) (int, error) {
+	n, err := v.rekey(primary, func(file File) bool {
+		key, _ := v.keys.Lookup(file.KeyID)
+		return ids[file.KeyID] && (len(key.Wrapped) == 0 || !isEnveloped(file))
+	})
+	if err != nil {
+		return n, err
+	}
+
+	pass, err := getnewpass()
+	if err != nil {
+		return n, err
+	}
+	defer func() {
+		if v.pass != pass {
+			pass.Destroy()
+		}
+	}()
+
+	if pass.Equal(current) && keyfile == nil {
+		return n, errors.New("new password must differ from the current one")
+	}
+
+	settings := v.settings
+	var digest []byte
+	switch {
+	case keyfile == nil:
+		digest, err = v.keyFileDigest()
+	case *keyfile == "":
+		settings.KeyFile = ""
+	default:
+		settings.KeyFile, digest, err = loadKeyFile(*keyfile, true)
+	}
+	if err != nil {
+		return n, err
+	}
+
+	keys := v.keys.Clone()
+	now := time.Now().UTC()
+
+	for i, key := range keys {
+		if len(key.Wrapped) == 0 || !ids[key.ID] {
+			continue
+		}
+
+		secret, err := v.secret(key.ID)
+		if err != nil {
+			return n, err
+		}
+
+		kdf, err := cryptio.NewKDF(key.KDF.Time, key.KDF.Memory, key.KDF.Threads)
+		if err != nil {
+			return n, err
+		}
+		if keys[i], err = wrapKey(key, kdf, pass.Bytes(), digest, secret); err != nil {
+			return n, err
+		}
+		keys[i].UpdatedAt = now
+	}
+
+	// Ключи и настройки фиксируются вместе, чтобы после сбоя ключевой файл
+	// в настройках соответствовал ключам.
+	j := journal{Keys: keys, Settings: &settings}
+	if err = v.save(JournalName, j); err != nil {
+		return n, err
+	}
+	if err = v.commit(j); err != nil {
+		return n, err
+	}
+
+	v.keyfile = digest
+	v.pass.Destroy()
+	v.pass = pass
+
+	return n, nil
+}
+
+// rekey перешифровывает ключом key файлы, для которых match возвращает true,
+// и возвращает их количество.
+//
+// Перешифрованные файлы записываются рядом с исходными, после чего ключ и их
+// конфигурации сохраняются в журнал. Появление журнала фиксирует операцию:
+// если она прервана раньше, то хранилище остаётся зашифрованным прежними
+// ключами, а если позже, то перешифрование завершается при следующем
+// открытии хранилища.
+func (v *Vault) rekey(key Key, match func(File) bool) (n int, err error) {
+	j := journal{Key: key}
+
+	defer func() {
+		if err != nil {
+			for _, file := range j.Files {
+				_ = v.data.Remove(file.ID + pendingExt)
+			}
+		}
+	}()
+
+	for _, file := range v.files {
+		if file.IsDeleted || !match(file) {
+			continue
+		}
+		var updated File
+		if updated, err = v.reencrypt(file, key); err != nil {
+			return 0, fmt.Errorf("re-encrypt %s: %w", file.ID, err)
+		}
+		j.Files = append(j.Files, updated)
+	}
+
+	if _, i := v.keys.Lookup(key.ID); i >= 0 && len(j.Files) == 0 {
+		return 0, nil
+	}
+
+	if err = v.save(JournalName, j); err != nil {
+		return 0, err
+	}
+
+	return len(j.Files), v.commit(j)
+}
+
+// reencrypt перешифровывает файл ключом key во временный файл хранилища.
+func (v *Vault) reencrypt(file File, key Key) (File, error) {
+	file, err := v.unseal(file)
+	if err != nil {
+		return file, err
+	}
+
+	f, err := v.data.Open(file.ID)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+
+	dec, err := v.newDecrypter(bufio.NewReader(f), file)
+	if err != nil {
+		return file, err
+	}
+
+	return v.write(file.ID+pendingExt, file, key, dec)
+}
+
+// commit применяет журнал перешифрования к хранилищу и удаляет его.
+// Повторное применение журнала безопасно.
+func (v *Vault) commit(j journal) error {
+	for _, file := range j.Files {
+		name := file.ID + pendingExt
+		if !v.data.Exists(name) {
+			continue
+		}
+		if err := os.Rename(v.data.Path(name), v.data.Path(file.ID)); err != nil {
+			return err
+		}
+	}
+
+	keys := j.Keys
+	if j.Key.ID != "" {
+		keys = append(keys.Clone(), j.Key)
+	}
+	v.keys = v.keys.Merge(keys)
+	if err := v.save(KeysName, v.keys); err != nil {
+		return err
+	}
+
+	if j.Settings != nil {
+		v.settings = *j.Settings
+		if err := v.save(SettingsName, v.settings); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range j.Files {
+		if _, i := v.files.Lookup(file.ID); i >= 0 {
+			v.files[i] = file
+		}
+	}
+	if err := v.save(FilesName, v.files); err != nil {
+		return err
+	}
+
+	return v.root.Remove(JournalName)
+}
+
+// recover завершает прерванное перешифрование хранилища, если журнал был
+// зафиксирован, и удаляет незафиксированные временные файлы в противном
+// случае.
+func (v *Vault) recover() error {
+	if v.root.Exists(JournalName) {
+		var j journal
+		if err := v.load(JournalName, &j); err != nil {
+			return err
+		}
+		return v.commit(j)
+	}
+
+	pending, err := filepath.Glob(v.data.Path("*" + pendingExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range pending {
+		if err = os.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,423 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// Формат записи данных для авторизации:
+//
+//	login   = version(1) | field(username) | field(password) | count(2) | field(url)*count |
+//	          field(notes) | count(2) | custom*count | count(2) | history*count
+//	custom  = kind(1) | field(name) | field(value)
+//	history = time(8) | field(password)
+//	field   = len(4) | data
+//
+// Записи версии 1 не содержат истории паролей. Записи, созданные до
+// появления версий, содержат только field(username) и field(password),
+// поэтому начинаются с нулевого старшего байта длины.
+
+const loginVersion = 2
+
+// Поля данных для авторизации.
+const (
+	LogpassUsername = "username"
+	LogpassPassword = "password"
+	LogpassURL      = "url"
+	LogpassNotes    = "notes"
+)
+
+// FieldKind определяет вид дополнительного поля данных для авторизации.
+type FieldKind uint8
+
+const (
+	FieldText   FieldKind = iota + 1 // Произвольный текст.
+	FieldHidden                      // Секрет, скрываемый при маскировании.
+	FieldURL                         // Адрес.
+	FieldEmail                       // Адрес электронной почты.
+)
+
+var fieldKindValues = []string{
+	"UNKNOWN",
+	"text",
+	"hidden",
+	"url",
+	"email",
+}
+
+func (k FieldKind) String() string {
+	if int(k) < len(fieldKindValues) {
+		return fieldKindValues[k]
+	}
+	return fieldKindValues[0]
+}
+
+// CustomField определяет дополнительное именованное поле данных для
+// авторизации. Значение хранится в срезе байт, чтобы его можно было очистить
+// при помощи Wipe.
+type CustomField struct {
+	Name  string
+	Kind  FieldKind
+	Value []byte
+}
+
+// Validate возвращает ошибку, если поле не валидно.
+func (cf CustomField) Validate() error {
+	if strings.TrimSpace(cf.Name) == "" {
+		return errors.New("field name must not be blank")
+	}
+	if strings.ContainsAny(cf.Name, "\r\n") {
+		return fmt.Errorf("field name %q must be a single line", cf.Name)
+	}
+	switch cf.Name {
+	case LogpassUsername, LogpassPassword, LogpassURL, LogpassNotes:
+		return fmt.Errorf("field name %q is reserved", cf.Name)
+	}
+	if len(cf.Value) == 0 {
+		return fmt.Errorf("field %q must not be blank", cf.Name)
+	}
+	if bytes.ContainsAny(cf.Value, "\r\n") {
+		return fmt.Errorf("field %q must be a single line", cf.Name)
+	}
+
+	switch cf.Kind {
+	case FieldText, FieldHidden:
+	case FieldURL:
+		return validateURL(string(cf.Value))
+	case FieldEmail:
+		addr, err := mail.ParseAddress(string(cf.Value))
+		if err != nil || addr.Address != string(cf.Value) {
+			return fmt.Errorf("field %q must be an email address", cf.Name)
+		}
+	default:
+		return fmt.Errorf("field %q has unknown kind %d", cf.Name, cf.Kind)
+	}
+
+	return nil
+}
+
+// PreviousPassword определяет прежний пароль учётной записи.
+type PreviousPassword struct {
+	Password  []byte
+	ChangedAt time.Time // Момент, когда пароль был заменён.
+}
+
+// UsernamePassword определяет данные для авторизации пользователя: имя,
+// пароль, адреса сайтов, заметки, дополнительные поля и историю прежних
+// паролей, начиная с последнего. Пароли хранятся в срезах байт, чтобы их
+// можно было очистить при помощи Wipe.
+type UsernamePassword struct {
+	Username string
+	Password []byte
+	URLs     []string
+	Notes    string
+	Fields   []CustomField
+	History  []PreviousPassword
+}
+
+// NewUsernamePassword конвертирует данные для авrоризации в UsernamePassword.
+func NewUsernamePassword(login, password string) UsernamePassword {
+	return UsernamePassword{Username: login, Password: []byte(password)}
+}
+
+func (up UsernamePassword) String() string {
+	b, err := up.AppendFormat(nil, Format{})
+	if err != nil {
+		return "<invalid>"
+	}
+	return string(b)
+}
+
+// Field возвращает дополнительное поле с именем name.
+func (up UsernamePassword) Field(name string) (CustomField, bool) {
+	for _, cf := range up.Fields {
+		if cf.Name == name {
+			return cf, true
+		}
+	}
+	return CustomField{}, false
+}
+
+// AppendFormat добавляет к b данные для авторизации в виде f: все заданные
+// поля по одному в строке или значение одного поля. Строки многострочных
+// заметок выводятся с отступом.
+func (up UsernamePassword) AppendFormat(b []byte, f Format) ([]byte, error) {
+	if err := up.Validate(); err != nil {
+		return b, err
+	}
+
+	secret := func(b, value []byte) []byte {
+		if f.Mask {
+			return append(b, bytes.Repeat([]byte("*"), len(value))...)
+		}
+		return append(b, value...)
+	}
+	custom := func(b []byte, cf CustomField) []byte {
+		if cf.Kind == FieldHidden {
+			return secret(b, cf.Value)
+		}
+		return append(b, cf.Value...)
+	}
+
+	switch f.Field {
+	case "":
+	case LogpassUsername:
+		return append(b, up.Username...), nil
+	case LogpassPassword:
+		return secret(b, up.Password), nil
+	case LogpassURL:
+		return append(b, strings.Join(up.URLs, "\n")...), nil
+	case LogpassNotes:
+		return append(b, strings.TrimRight(up.Notes, "\n")...), nil
+	default:
+		cf, ok := up.Field(f.Field)
+		if !ok {
+			names := []string{LogpassUsername, LogpassPassword, LogpassURL, LogpassNotes}
+			for _, cf := range up.Fields {
+				names = append(names, cf.Name)
+			}
+			return b, fmt.Errorf("unknown login field %q, expected one of %s", f.Field, strings.Join(names, ", "))
+		}
+		return custom(b, cf), nil
+	}
+
+	b = append(b, LogpassUsername+": "+up.Username...)
+	b = append(b, "\n"+LogpassPassword+": "...)
+	b = secret(b, up.Password)
+	for _, u := range up.URLs {
+		b = append(b, "\n"+LogpassURL+": "+u...)
+	}
+	for _, cf := range up.Fields {
+		b = append(b, '\n')
+		b = append(b, cf.Name+": "...)
+		b = custom(b, cf)
+	}
+	if notes := strings.TrimRight(up.Notes, "\n"); notes != "" {
+		b = append(b, "\n"+LogpassNotes+": "...)
+		b = append(b, strings.ReplaceAll(notes, "\n", "\n  ")...)
+	}
+
+	return b, nil
+}
+
+// Validate возвращает ошибку, если данные для авторизации не валидны.
+func (up UsernamePassword) Validate() error {
+	if up.Username == "" {
+		return errors.New("username must not be blank")
+	}
+	if len(up.Password) == 0 {
+		return errors.New("password must not be blank")
+	}
+	for _, u := range up.URLs {
+		if err := validateURL(u); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool, len(up.Fields))
+	for _, cf := range up.Fields {
+		if err := cf.Validate(); err != nil {
+			return err
+		}
+		if names[cf.Name] {
+			return fmt.Errorf("field %q is repeated", cf.Name)
+		}
+		names[cf.Name] = true
+	}
+
+	for _, prev := range up.History {
+		if len(prev.Password) == 0 {
+			return errors.New("previous password must not be blank")
+		}
+	}
+
+	return nil
+}
+
+// Wipe заполняет пароль, значения дополнительных полей и прежние пароли
+// нулями.
+func (up *UsernamePassword) Wipe() {
+	secmem.Wipe(up.Password)
+	for _, cf := range up.Fields {
+		secmem.Wipe(cf.Value)
+	}
+	for _, prev := range up.History {
+		secmem.Wipe(prev.Password)
+	}
+}
+
+func (up UsernamePassword) MarshalBinary() ([]byte, error) {
+	if len(up.URLs) > 0xffff || len(up.Fields) > 0xffff || len(up.History) > 0xffff {
+		return nil, errors.New("login has too many urls, fields or previous passwords")
+	}
+
+	size := 1 + 4 + len(up.Username) + 4 + len(up.Password) + 2 + 4 + len(up.Notes) + 2 + 2
+	for _, u := range up.URLs {
+		size += 4 + len(u)
+	}
+	for _, cf := range up.Fields {
+		size += 1 + 4 + len(cf.Name) + 4 + len(cf.Value)
+	}
+	for _, prev := range up.History {
+		size += 8 + 4 + len(prev.Password)
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, loginVersion)
+	b = appendField(b, []byte(up.Username))
+	b = appendField(b, up.Password)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(up.URLs)))
+	for _, u := range up.URLs {
+		b = appendField(b, []byte(u))
+	}
+	b = appendField(b, []byte(up.Notes))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(up.Fields)))
+	for _, cf := range up.Fields {
+		b = append(b, byte(cf.Kind))
+		b = appendField(b, []byte(cf.Name))
+		b = appendField(b, cf.Value)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(up.History)))
+	for _, prev := range up.History {
+		b = binary.BigEndian.AppendUint64(b, uint64(prev.ChangedAt.UnixNano()))
+		b = appendField(b, prev.Password)
+	}
+
+	return b, nil
+}
+
+func (up *UsernamePassword) UnmarshalBinary(data []byte) error {
+	errCorrupted := errors.New("login is corrupted")
+
+	if len(data) == 0 {
+		return errCorrupted
+	}
+	if data[0] == 0 {
+		return up.unmarshalLegacy(data)
+	}
+	version := data[0]
+	if version > loginVersion {
+		return fmt.Errorf("login version %d is not supported", version)
+	}
+	data = data[1:]
+
+	var p UsernamePassword
+	var field []byte
+	var ok bool
+
+	if field, data, ok = readField(data); !ok {
+		return errCorrupted
+	}
+	p.Username = string(field)
+
+	if field, data, ok = readField(data); !ok {
+		return errCorrupted
+	}
+	p.Password = bytes.Clone(field)
+
+	var n int
+	if n, data, ok = readCount(data); !ok {
+		return errCorrupted
+	}
+	for i := 0; i < n; i++ {
+		if field, data, ok = readField(data); !ok {
+			return errCorrupted
+		}
+		p.URLs = append(p.URLs, string(field))
+	}
+
+	if field, data, ok = readField(data); !ok {
+		return errCorrupted
+	}
+	p.Notes = string(field)
+
+	if n, data, ok = readCount(data); !ok {
+		return errCorrupted
+	}
+	for i := 0; i < n; i++ {
+		if len(data) < 1 {
+			return errCorrupted
+		}
+		cf := CustomField{Kind: FieldKind(data[0])}
+		if field, data, ok = readField(data[1:]); !ok {
+			return errCorrupted
+		}
+		cf.Name = string(field)
+		if field, data, ok = readField(data); !ok {
+			return errCorrupted
+		}
+		cf.Value = bytes.Clone(field)
+		p.Fields = append(p.Fields, cf)
+	}
+
+	if version >= 2 {
+		if n, data, ok = readCount(data); !ok {
+			return errCorrupted
+		}
+		for i := 0; i < n; i++ {
+			if len(data) < 8 {
+				return errCorrupted
+			}
+			prev := PreviousPassword{ChangedAt: time.Unix(0, int64(binary.BigEndian.Uint64(data))).UTC()}
+			if field, data, ok = readField(data[8:]); !ok {
+				return errCorrupted
+			}
+			prev.Password = bytes.Clone(field)
+			p.History = append(p.History, prev)
+		}
+	}
+
+	if len(data) != 0 {
+		return errCorrupted
+	}
+
+	*up = p
+
+	return nil
+}
+
+// unmarshalLegacy считывает запись прежнего формата, содержащую только имя
+// пользователя и пароль.
+func (up *UsernamePassword) unmarshalLegacy(data []byte) error {
+	username, data, ok := readField(data)
+	if !ok {
+		return errors.New("login is corrupted")
+	}
+	password, data, ok := readField(data)
+	if !ok || len(data) != 0 {
+		return errors.New("password is corrupted")
+	}
+
+	*up = UsernamePassword{
+		Username: string(username),
+		Password: bytes.Clone(password),
+	}
+
+	return nil
+}
+
+// readCount считывает из data количество элементов и возвращает его и
+// остаток data; ok равен false, если data повреждены.
+func readCount(data []byte) (n int, rest []byte, ok bool) {
+	if len(data) < 2 {
+		return 0, nil, false
+	}
+	return int(binary.BigEndian.Uint16(data)), data[2:], true
+}
+
+// validateURL возвращает ошибку, если s не является абсолютным адресом.
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("url %q must be absolute, e.g. https://example.com", s)
+	}
+	return nil
+}
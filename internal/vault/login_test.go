@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testLogin() UsernamePassword {
+	up := NewUsernamePassword("john", "secret")
+	up.URLs = []string{"https://example.com/login", "https://m.example.com"}
+	up.Notes = "recovery codes\nin the safe"
+	up.Fields = []CustomField{
+		{Name: "pin", Kind: FieldHidden, Value: []byte("1234")},
+		{Name: "email", Kind: FieldEmail, Value: []byte("john@example.com")},
+		{Name: "team", Kind: FieldText, Value: []byte("ops")},
+	}
+	up.History = []PreviousPassword{
+		{Password: []byte("qwerty"), ChangedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)},
+		{Password: []byte("123456"), ChangedAt: time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)},
+	}
+	return up
+}
+
+func TestUsernamePassword_Binary(t *testing.T) {
+	up := testLogin()
+	require.NoError(t, up.Validate())
+
+	data, err := up.MarshalBinary()
+	require.NoError(t, err)
+
+	var got UsernamePassword
+	require.NoError(t, got.UnmarshalBinary(data))
+	require.Equal(t, up, got)
+
+	require.Error(t, got.UnmarshalBinary(data[:len(data)-1]))
+
+	// Записи версии 1 не содержат истории паролей.
+	up.History = nil
+	data, err = up.MarshalBinary()
+	require.NoError(t, err)
+	data[0] = 1
+	require.NoError(t, got.UnmarshalBinary(data[:len(data)-2]))
+	require.Equal(t, up, got)
+
+	// Записи прежнего формата содержат только имя пользователя и пароль.
+	var legacy []byte
+	legacy = binary.BigEndian.AppendUint32(legacy, 4)
+	legacy = append(legacy, "john"...)
+	legacy = binary.BigEndian.AppendUint32(legacy, 6)
+	legacy = append(legacy, "secret"...)
+
+	require.NoError(t, got.UnmarshalBinary(legacy))
+	require.Equal(t, NewUsernamePassword("john", "secret"), got)
+}
+
+func TestUsernamePassword_AppendFormat(t *testing.T) {
+	up := testLogin()
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{Format{}, "username: john\npassword: secret\nurl: https://example.com/login\nurl: https://m.example.com\n" +
+			"pin: 1234\nemail: john@example.com\nteam: ops\nnotes: recovery codes\n  in the safe"},
+		{Format{Field: LogpassPassword}, "secret"},
+		{Format{Field: LogpassPassword, Mask: true}, "******"},
+		{Format{Field: LogpassURL}, "https://example.com/login\nhttps://m.example.com"},
+		{Format{Field: LogpassNotes}, "recovery codes\nin the safe"},
+		{Format{Field: "pin", Mask: true}, "****"},
+		{Format{Field: "email", Mask: true}, "john@example.com"},
+	}
+
+	for _, tc := range testCases {
+		b, err := up.AppendFormat(nil, tc.format)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(b))
+	}
+
+	_, err := up.AppendFormat(nil, Format{Field: "cvv"})
+	require.Error(t, err)
+}
+
+func TestUsernamePassword_ValidateFields(t *testing.T) {
+	testCases := []struct {
+		name  string
+		up    func(up *UsernamePassword)
+		valid bool
+	}{
+		{"relative url", func(up *UsernamePassword) { up.URLs = []string{"example.com"} }, false},
+		{"reserved name", func(up *UsernamePassword) { up.Fields[0].Name = LogpassPassword }, false},
+		{"repeated name", func(up *UsernamePassword) { up.Fields[2].Name = "pin" }, false},
+		{"invalid email", func(up *UsernamePassword) { up.Fields[1].Value = []byte("john") }, false},
+		{"invalid url field", func(up *UsernamePassword) { up.Fields[2].Kind = FieldURL }, false},
+		{"multiline value", func(up *UsernamePassword) { up.Fields[2].Value = []byte("a\nb") }, false},
+		{"unknown kind", func(up *UsernamePassword) { up.Fields[2].Kind = 0 }, false},
+		{"url field", func(up *UsernamePassword) {
+			up.Fields[2] = CustomField{Name: "admin", Kind: FieldURL, Value: []byte("https://example.com/admin")}
+		}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			up := testLogin()
+			tc.up(&up)
+			require.Equal(t, tc.valid, up.Validate() == nil)
+		})
+	}
+}
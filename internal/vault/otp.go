@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// OTPCode определяет одноразовый пароль.
+type OTPCode struct {
+	Code      string        // Пароль.
+	Kind      string        // Вид пароля: otp.TOTP или otp.HOTP.
+	Remaining time.Duration // Оставшееся время действия пароля TOTP.
+	Counter   uint64        // Значение счётчика, для которого вычислен пароль HOTP.
+}
+
+// AddOTP добавляет в хранилище зашифрованный ключ генерации одноразовых
+// паролей.
+func (v *Vault) AddOTP(description string, key otp.Key, recipients ...*cryptio.Recipient) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	data, err := otpKey{key}.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+	return v.add(description, TypeTOTP, bytes.NewReader(data), recipients)
+}
+
+// OTP вычисляет одноразовый пароль по ключу с ID id в момент now. Счётчик
+// ключа HOTP увеличивается, и ключ сохраняется, чтобы новое значение
+// синхронизировалось с другими устройствами.
+func (v *Vault) OTP(id string, now time.Time) (OTPCode, error) {
+	file, err := v.Stat(id)
+	if err != nil {
+		return OTPCode{}, err
+	}
+	if file.Type != TypeTOTP {
+		return OTPCode{}, fmt.Errorf("%s is %s, not %s", id, file.Type, TypeTOTP)
+	}
+
+	var key otpKey
+	if err = v.unmarshal(file, &key); err != nil {
+		return OTPCode{}, err
+	}
+	defer key.Wipe()
+
+	code := OTPCode{Kind: key.Kind}
+
+	if key.Kind == otp.TOTP {
+		code.Code, code.Remaining, err = key.At(now)
+		return code, err
+	}
+
+	code.Counter = key.Counter
+	if code.Code, err = key.Generate(key.Counter); err != nil {
+		return OTPCode{}, err
+	}
+
+	key.Counter++
+	next, err := key.MarshalBinary()
+	if err != nil {
+		return OTPCode{}, err
+	}
+	defer secmem.Wipe(next)
+
+	// Увеличение счётчика не является правкой ключа и не создаёт ревизию.
+	if err = v.edit(id, nil, bytes.NewReader(next), false); err != nil {
+		return OTPCode{}, err
+	}
+
+	return code, nil
+}
+
+// otpKey определяет хранимый ключ генерации одноразовых паролей в формате
+// otpauth:// URI.
+type otpKey struct {
+	otp.Key
+}
+
+func (k otpKey) MarshalBinary() ([]byte, error) {
+	return []byte(k.URI()), nil
+}
+
+func (k *otpKey) UnmarshalBinary(data []byte) error {
+	key, err := otp.Parse(string(data))
+	if err != nil {
+		return err
+	}
+	k.Key = key
+	return nil
+}
+
+// AppendFormat добавляет к b ключ в формате otpauth:// URI.
+func (k otpKey) AppendFormat(b []byte, _ Format) ([]byte, error) {
+	return append(b, k.URI()...), nil
+}
+
+// Wipe заполняет секрет ключа нулями.
+func (k *otpKey) Wipe() {
+	secmem.Wipe(k.Secret)
+}
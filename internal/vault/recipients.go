@@ -0,0 +1,101 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+)
+
+// GenerateIdentity генерирует новый закрытый ключ получателя и сохраняет его
+// в хранилище.
+func (v *Vault) GenerateIdentity() (*cryptio.Identity, error) {
+	id, err := cryptio.GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	if err = v.ImportIdentity(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// ImportIdentity сохраняет закрытый ключ получателя в хранилище, обернув его
+// основным ключом хранилища.
+func (v *Vault) ImportIdentity(id *cryptio.Identity) error {
+	recipient := id.Recipient().String()
+	if _, i := v.ids.Lookup(recipient); i >= 0 {
+		return fmt.Errorf("identity %s already exists", recipient)
+	}
+
+	key, err := v.primaryKey()
+	if err != nil {
+		return err
+	}
+
+	secret, err := v.secret(key.ID)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := cryptio.Wrap(secret, id.Bytes())
+	if err != nil {
+		return err
+	}
+
+	v.ids = append(v.ids, Identity{
+		Recipient: recipient,
+		KeyID:     key.ID,
+		Wrapped:   wrapped,
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return v.save(IdentitiesName, v.ids)
+}
+
+// Identities возвращает закрытые ключи получателя, сохранённые в хранилище.
+func (v *Vault) Identities() Identities {
+	ids := make(Identities, len(v.ids))
+	copy(ids, v.ids)
+	return ids
+}
+
+// identity возвращает закрытый ключ получателя.
+func (v *Vault) identity(id Identity) (*cryptio.Identity, error) {
+	secret, err := v.secret(id.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := cryptio.Unwrap(secret, id.Wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap identity %s: %w", id.Recipient, err)
+	}
+
+	return cryptio.NewIdentity(b)
+}
+
+// recipientKey возвращает ключ file, расшифрованный закрытым ключом одного из
+// получателей; ok равен false, если ни один из получателей не найден в
+// хранилище.
+func (v *Vault) recipientKey(file File) (dek []byte, ok bool, err error) {
+	for _, r := range file.Recipients {
+		id, i := v.ids.Lookup(r.Key)
+		if i < 0 {
+			continue
+		}
+
+		identity, err := v.identity(id)
+		if err != nil {
+			return nil, true, err
+		}
+
+		dek, err = identity.Unwrap(r.Wrapped)
+		if err != nil {
+			return nil, true, fmt.Errorf("unwrap key of %s: %w", file.ID, err)
+		}
+
+		return dek, true, nil
+	}
+	return nil, false, nil
+}
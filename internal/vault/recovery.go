@@ -0,0 +1,164 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Формат секрета набора восстановления:
+//
+//	secret = version(1) | count(1) | entry_1 | ... | entry_n | checksum(8)
+//	entry  = len(1) | id | len(1) | key
+//
+// где key — ключ шифрования с идентификатором id, а checksum — первые байты
+// SHA-256 остальных полей.
+
+const (
+	recoveryVersion      = 1
+	recoveryChecksumSize = 8
+)
+
+// RecoverySecret возвращает секрет для набора восстановления: ключи
+// шифрования основного ключа хранилища, ключей, которыми зашифрованы файлы, и
+// закрытых ключей получателя, доступные по текущему мастер-паролю.
+func (v *Vault) RecoverySecret() ([]byte, error) {
+	primary, err := v.primaryKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]bool{primary.ID: true}
+	for _, file := range v.files {
+		if !file.IsDeleted {
+			ids[file.KeyID] = true
+		}
+	}
+	for _, id := range v.ids {
+		ids[id.KeyID] = true
+	}
+
+	secrets := make(map[string][]byte, len(ids))
+	for id := range ids {
+		if _, i := v.keys.Lookup(id); id != "" && i < 0 {
+			continue // Ключ другого хранилища, например, у импортированного файла.
+		}
+		secret, err := v.secret(id)
+		if id != primary.ID && (errors.Is(err, ErrWrongPassword) || errors.Is(err, ErrKeyFileRequired)) {
+			continue // Ключ другого пароля, например, с другого устройства.
+		}
+		if err != nil {
+			return nil, err
+		}
+		secrets[id] = secret
+	}
+
+	return encodeRecovery(secrets)
+}
+
+// Recover восстанавливает доступ к хранилищу по секрету набора
+// восстановления без текущего мастер-пароля: запрашивает новый мастер-пароль
+// и обёртывает им ключи данных из секрета. Параметр keyfile имеет тот же
+// смысл, что и в ChangePassword. Возвращает количество перешифрованных файлов.
+func (v *Vault) Recover(secret []byte, keyfile *string) (int, error) {
+	secrets, err := decodeRecovery(secret)
+	if err != nil {
+		return 0, err
+	}
+
+	primary, ok := v.keys.Primary()
+	if !ok {
+		return 0, errors.New("master password is not set")
+	}
+	if _, ok = secrets[primary.ID]; !ok || len(primary.Wrapped) == 0 {
+		return 0, errors.New("the recovery kit does not match the primary key of the vault")
+	}
+
+	ids := make(map[string]bool, len(secrets))
+	for id, secret := range secrets {
+		v.cache(id, secret)
+		ids[id] = true
+	}
+
+	return v.resetPassword(primary, ids, keyfile, nil)
+}
+
+func encodeRecovery(secrets map[string][]byte) ([]byte, error) {
+	if len(secrets) > 255 {
+		return nil, errors.New("too many keys")
+	}
+
+	ids := make([]string, 0, len(secrets))
+	for id := range secrets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b bytes.Buffer
+	b.WriteByte(recoveryVersion)
+	b.WriteByte(byte(len(ids)))
+
+	for _, id := range ids {
+		secret := secrets[id]
+		if len(id) > 255 || len(secret) > 255 {
+			return nil, fmt.Errorf("key %s is too long", id)
+		}
+		b.WriteByte(byte(len(id)))
+		b.WriteString(id)
+		b.WriteByte(byte(len(secret)))
+		b.Write(secret)
+	}
+
+	sum := sha256.Sum256(b.Bytes())
+	b.Write(sum[:recoveryChecksumSize])
+
+	return b.Bytes(), nil
+}
+
+func decodeRecovery(b []byte) (map[string][]byte, error) {
+	errMalformed := errors.New("the recovery secret is malformed")
+
+	if len(b) < 2+recoveryChecksumSize {
+		return nil, errMalformed
+	}
+
+	data, checksum := b[:len(b)-recoveryChecksumSize], b[len(b)-recoveryChecksumSize:]
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:recoveryChecksumSize], checksum) {
+		return nil, errors.New("the recovery secret checksum mismatch")
+	}
+	if data[0] != recoveryVersion {
+		return nil, errors.New("the recovery secret version is not supported")
+	}
+
+	n := int(data[1])
+	data = data[2:]
+
+	next := func() ([]byte, bool) {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return nil, false
+		}
+		field := data[1 : 1+int(data[0])]
+		data = data[1+int(data[0]):]
+		return field, true
+	}
+
+	secrets := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		id, ok := next()
+		if !ok {
+			return nil, errMalformed
+		}
+		secret, ok := next()
+		if !ok {
+			return nil, errMalformed
+		}
+		secrets[string(id)] = bytes.Clone(secret)
+	}
+	if len(data) != 0 {
+		return nil, errMalformed
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,174 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// DefaultRevisionLimit определяет количество прежних версий элемента,
+// хранимых по умолчанию.
+const DefaultRevisionLimit = 10
+
+// RevisionLimit возвращает количество прежних версий элемента, хранимых на
+// текущем устройстве.
+func (v *Vault) RevisionLimit() int {
+	if v.settings.RevisionLimit == nil {
+		return DefaultRevisionLimit
+	}
+	return *v.settings.RevisionLimit
+}
+
+// SetRevisionLimit задаёт количество прежних версий элемента, хранимых на
+// текущем устройстве, и сразу удаляет лишние версии всех элементов; 0
+// отключает ревизии.
+func (v *Vault) SetRevisionLimit(n int) error {
+	if n < 0 {
+		return errors.New("revision limit must not be negative")
+	}
+
+	settings := v.settings
+	settings.RevisionLimit = &n
+	if err := v.save(SettingsName, settings); err != nil {
+		return err
+	}
+	v.settings = settings
+
+	for _, file := range v.files {
+		if file.Parent == "" && file.Revises == "" && !file.IsDeleted {
+			v.pruneRevisions(file.ID)
+		}
+	}
+
+	return v.save(FilesName, v.files)
+}
+
+// device возвращает имя текущего устройства.
+func (v *Vault) device() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// revision переносит текущую версию элемента file в ревизию с новым ID и
+// возвращает её конфигурацию. Данные ревизии не перешифровываются, а
+// заново запечатываются только тип, описание и устройство, которые
+// привязаны к ID.
+func (v *Vault) revision(file File) (File, error) {
+	rev := file
+	rev.ID = generateID(v.files.Contains)
+	rev.Revises = file.ID
+	rev.Revision = max(file.Revision, 1)
+
+	if len(file.Sealed) > 0 {
+		key, err := v.fileKey(file)
+		if err != nil {
+			return File{}, err
+		}
+		rev.Sealed, err = seal(key, rev)
+		secmem.Wipe(key)
+		if err != nil {
+			return File{}, err
+		}
+	}
+
+	if err := os.Rename(v.data.Path(file.ID), v.data.Path(rev.ID)); err != nil {
+		return File{}, err
+	}
+
+	return rev, nil
+}
+
+// revisions возвращает индексы неудалённых ревизий элемента с ID id в
+// v.files, начиная с последней.
+func (v *Vault) revisions(id string) []int {
+	var revs []int
+	for i, file := range v.files {
+		if file.Revises == id && !file.IsDeleted {
+			revs = append(revs, i)
+		}
+	}
+
+	sort.SliceStable(revs, func(i, j int) bool {
+		a, b := v.files[revs[i]], v.files[revs[j]]
+		if a.Revision != b.Revision {
+			return a.Revision > b.Revision
+		}
+		return a.LastUpdate.After(b.LastUpdate)
+	})
+
+	return revs
+}
+
+// pruneRevisions удаляет самые старые ревизии элемента с ID id сверх
+// RevisionLimit. Ревизии помечаются удалёнными, чтобы удаление
+// синхронизировалось с другими устройствами.
+func (v *Vault) pruneRevisions(id string) {
+	revs := v.revisions(id)
+	if limit := v.RevisionLimit(); len(revs) > limit {
+		for _, i := range revs[limit:] {
+			_ = v.data.Remove(v.files[i].ID)
+			v.files[i].IsDeleted = true
+		}
+	}
+}
+
+// Log возвращает версии элемента с ID id с расшифрованными типом, описанием
+// и устройством, начиная с текущей.
+func (v *Vault) Log(id string) (Files, error) {
+	file, err := v.Stat(id)
+	if err != nil {
+		return nil, err
+	}
+	if file.Revises != "" {
+		return nil, fmt.Errorf("%s is a revision of %s", id, file.Revises)
+	}
+	if file.Revision == 0 {
+		file.Revision = 1
+	}
+
+	files := Files{file}
+	for _, i := range v.revisions(id) {
+		rev, err := v.unseal(v.files[i])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, rev)
+	}
+
+	return files, nil
+}
+
+// Restore делает ревизию revision элемента с ID id его текущей версией:
+// содержимое и описание ревизии сохраняются как новая версия, а текущая
+// версия становится ревизией, поэтому восстановление тоже можно отменить.
+func (v *Vault) Restore(id string, revision int) error {
+	files, err := v.Log(id)
+	if err != nil {
+		return err
+	}
+	if files[0].Revision == revision {
+		return fmt.Errorf("revision %d of %s is already current", revision, id)
+	}
+
+	for _, rev := range files[1:] {
+		if rev.Revision != revision {
+			continue
+		}
+
+		rc, err := v.decrypt(rev)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		return v.Edit(id, &rev.Description, rc)
+	}
+
+	return fmt.Errorf("%s has no revision %d", id, revision)
+}
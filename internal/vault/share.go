@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Export упаковывает зашифрованный файл с ID id и его вложения в архив tar,
+// который может быть добавлен в хранилище получателя при помощи Import.
+// Ключи хранилища в архив не попадают.
+func (v *Vault) Export(id string) (_ *os.File, err error) {
+	file, i := v.files.Lookup(id)
+	if i < 0 {
+		return nil, fmt.Errorf("%s not found", id)
+	}
+	if file.IsDeleted {
+		return nil, fmt.Errorf("%s has been deleted", id)
+	}
+
+	files := Files{file}
+	for _, child := range v.files {
+		if child.Parent == id && !child.IsDeleted {
+			files = append(files, child)
+		}
+	}
+
+	temp, err := v.root.Temp("temp-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			name := temp.Name()
+			_ = temp.Close()
+			_ = os.RemoveAll(name)
+		}
+	}()
+
+	var config bytes.Buffer
+	if _, err = files.WriteTo(&config); err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(temp)
+	tw := tar.NewWriter(buf)
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     FilesName,
+		Mode:     0o600,
+		Size:     int64(config.Len()),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = config.WriteTo(tw); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err = v.packFile(tw, file.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if err = buf.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err = temp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return temp, nil
+}
+
+// Import добавляет в хранилище зашифрованные файлы из архива, созданного
+// Export, и возвращает их количество. Файл, который уже есть в хранилище,
+// заменяется только файлом, зашифрованным тем же ключом.
+func (v *Vault) Import(src io.Reader) (int, error) {
+	tr := tar.NewReader(src)
+
+	var imported Files
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if hdr.Name == FilesName {
+			if imported != nil {
+				return 0, fmt.Errorf("archive contains several %s", FilesName)
+			}
+			if _, err = imported.ReadFrom(io.LimitReader(tr, hdr.Size)); err != nil {
+				return 0, err
+			}
+			for _, file := range imported {
+				if file.IsDeleted {
+					return 0, fmt.Errorf("%s has been deleted", file.ID)
+				}
+				if matched, i := v.files.Lookup(file.ID); i >= 0 && matched.KeyID != file.KeyID {
+					return 0, fmt.Errorf("%s already exists", file.ID)
+				}
+			}
+			for _, file := range imported {
+				if _, i := v.files.Lookup(file.ID); i >= 0 {
+					v.files[i] = file
+				} else {
+					v.files = append(v.files, file)
+				}
+			}
+			sort.Stable(v.files)
+			continue
+		}
+
+		if _, i := imported.Lookup(filepath.Base(hdr.Name)); i < 0 {
+			return 0, fmt.Errorf("unexpected entry %s", hdr.Name)
+		}
+		if err = v.unpack(hdr, tr); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, file := range imported {
+		if !v.data.Exists(file.ID) {
+			return 0, fmt.Errorf("%s has no data", file.ID)
+		}
+	}
+
+	return len(imported), v.save(FilesName, v.files)
+}
@@ -2,7 +2,9 @@ package vault
 
 import (
 	"encoding/binary"
-	"errors"
+	"io"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
 )
 
 // Type определяет тип защищаемой информации.
@@ -14,6 +16,9 @@ const (
 	TypeBinary
 	TypeCard
 	TypeLogpass
+	TypeNote
+	TypeTOTP
+	TypeEnv
 )
 
 var typeValues = []string{
@@ -21,6 +26,9 @@ var typeValues = []string{
 	"BINARY",
 	"CARD",
 	"LOGPASS",
+	"NOTE",
+	"TOTP",
+	"ENV",
 }
 
 func (t Type) String() string {
@@ -30,132 +38,61 @@ func (t Type) String() string {
 	return typeValues[0]
 }
 
-// BankCard определяет номер банковской карты.
-type BankCard [16]byte
-
-// NewBankCard конвертирует number в BankCard.
-func NewBankCard(number string) BankCard {
-	var c BankCard
-	var n int
-	for i := 0; i < len(number); i++ {
-		v := number[i]
-		if v >= '0' && v <= '9' && n < len(c) {
-			c[n] = v
-			n++
-		}
-	}
-	return c
+// Format определяет вид расшифрованных данных при выводе.
+type Format struct {
+	Field string // Выводимое поле; пустая строка означает все поля.
+	Mask  bool   // Маскировать номера карт, CVV, пароли и значения переменных.
 }
 
-func (c BankCard) String() string {
-	if c.Validate() != nil {
-		return "<invalid>"
-	}
-	return string(c[0:4]) + " " + string(c[4:8]) + " " + string(c[8:12]) + " " + string(c[12:16])
+// secretValue определяет расшифрованное значение, текстовое представление
+// которого можно получить без промежуточных строк.
+type secretValue interface {
+	UnmarshalBinary(data []byte) error
+	AppendFormat(b []byte, f Format) ([]byte, error)
+	Wipe()
 }
 
-// Validate проверяет по алгоритму Луна контрольную сумму номера банковской
-// карты.
-func (c BankCard) Validate() error {
-	var sum int
-	parity := len(c) % 2
-	for i := 0; i < len(c); i++ {
-		digit := int(c[i] - '0')
-		if i%2 == parity {
-			digit *= 2
-			if digit > 9 {
-				digit -= 9
-			}
-		}
-		sum += digit
+// readSecret считывает из src значение value и возвращает его текстовое
+// представление в виде f с переводом строки в защищённой памяти, которая
+// очищается при закрытии.
+func readSecret(src io.Reader, value secretValue, f Format) (io.ReadCloser, error) {
+	data, err := secmem.ReadAll(src)
+	if err != nil {
+		return nil, err
 	}
-	if sum%10 != 0 {
-		return errors.New("bank card number is invalid")
-	}
-	return nil
-}
-
-func (c BankCard) MarshalBinary() ([]byte, error) {
-	return c[:], nil
-}
+	defer data.Destroy()
 
-func (c *BankCard) UnmarshalBinary(data []byte) error {
-	p := *c
-	if len(data) != len(p) {
-		return errors.New("data is invalid")
+	if err = value.UnmarshalBinary(data.Bytes()); err != nil {
+		return nil, err
 	}
-	copy(p[:], data)
-	*c = p
-	return nil
-}
-
-// UsernamePassword определяет данные для авторизации пользователя.
-type UsernamePassword struct {
-	Username string
-	Password string
-}
 
-// NewUsernamePassword конвертирует данные для авrоризации в UsernamePassword.
-func NewUsernamePassword(login, password string) UsernamePassword {
-	return UsernamePassword{Username: login, Password: password}
-}
-
-func (up UsernamePassword) String() string {
-	if up.Validate() != nil {
-		return "<invalid>"
+	// Текстовое представление не длиннее двоичного с запасом на разделители.
+	text, err := value.AppendFormat(make([]byte, 0, data.Len()+64), f)
+	if err != nil {
+		return nil, err
 	}
-	return up.Username + ":" + up.Password
-}
+	text = append(text, '\n')
+	defer secmem.Wipe(text)
 
-// Validate возвращает ошибку, если данные для авторизации не валидны.
-func (up UsernamePassword) Validate() error {
-	if up.Username == "" {
-		return errors.New("username must not be blank")
-	}
-	if up.Password == "" {
-		return errors.New("password must not be blank")
-	}
-	return nil
+	return secmem.NewReader(secmem.Copy(text)), nil
 }
 
-func (up UsernamePassword) MarshalBinary() ([]byte, error) {
-	b := make([]byte, 0, len(up.Username)+len(up.Password)+4+4)
-	b = binary.BigEndian.AppendUint32(b, uint32(len(up.Username)))
-	b = append(b, up.Username...)
-	b = binary.BigEndian.AppendUint32(b, uint32(len(up.Password)))
-	b = append(b, up.Password...)
-	return b, nil
+// appendField добавляет к b поле с длиной.
+func appendField(b, field []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+	return append(b, field...)
 }
 
-func (up *UsernamePassword) UnmarshalBinary(data []byte) error {
-	if len(data) < 8 {
-		return errors.New("data is too short")
-	}
-
-	n := binary.BigEndian.Uint32(data[:4])
-	data = data[4:]
-
-	if len(data) < int(n) {
-		return errors.New("login is corrupted")
-	}
-
-	p := *up
-	p.Username = string(data[:n])
-	data = data[n:]
-
+// readField считывает поле с длиной из data и возвращает его и остаток
+// data; ok равен false, если data повреждены.
+func readField(data []byte) (field, rest []byte, ok bool) {
 	if len(data) < 4 {
-		return errors.New("data is corrupted")
+		return nil, nil, false
 	}
-
-	n = binary.BigEndian.Uint32(data[:4])
+	n := binary.BigEndian.Uint32(data)
 	data = data[4:]
-
-	if len(data) < int(n) {
-		return errors.New("password is corrupted")
+	if uint64(len(data)) < uint64(n) {
+		return nil, nil, false
 	}
-
-	p.Password = string(data[:n])
-	*up = p
-
-	return nil
+	return data[:n], data[n:], true
 }
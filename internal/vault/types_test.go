@@ -1,6 +1,8 @@
 package vault
 
 import (
+	"bytes"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -42,3 +44,35 @@ func TestUsernamePassword_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestReadSecret(t *testing.T) {
+	logpass := NewUsernamePassword("user", "pass")
+	data, err := logpass.MarshalBinary()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{Format{}, "username: user\npassword: pass\n"},
+		{Format{Mask: true}, "username: user\npassword: ****\n"},
+		{Format{Field: LogpassPassword}, "pass\n"},
+	}
+
+	for _, tc := range testCases {
+		var up UsernamePassword
+		rc, err := readSecret(bytes.NewReader(data), &up, tc.format)
+		require.NoError(t, err)
+
+		text, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(text))
+		require.NoError(t, rc.Close())
+
+		up.Wipe()
+		require.Equal(t, []byte{0, 0, 0, 0}, up.Password)
+	}
+
+	_, err = readSecret(bytes.NewReader(data), new(UsernamePassword), Format{Field: "cvv"})
+	require.Error(t, err)
+}
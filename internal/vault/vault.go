@@ -4,21 +4,27 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"encoding"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
 	"github.com/sergeizaitcev/gophkeeper/pkg/hashio"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
 	"github.com/sergeizaitcev/gophkeeper/pkg/workdir"
 )
 
 const (
 	DirName     = ".gophkeeper" // Рабочая директория.
 	DataDirName = "data"        // Директория с зашифрованными файлами.
+
+	// Директория с копиями файлов, зашифрованных устаревшим способом.
+	LegacyDirName = "legacy"
 )
 
 type readWriter interface {
@@ -28,10 +34,17 @@ type readWriter interface {
 
 // Vault определяет хранилище зашифрованных файлов.
 type Vault struct {
-	root   workdir.Dir
-	data   workdir.Dir
-	remote Remote
-	files  Files
+	root     workdir.Dir
+	data     workdir.Dir
+	remote   Remote
+	files    Files
+	keys     Keyring
+	ids      Identities
+	settings Settings
+
+	pass    *secmem.Buffer            // Мастер-пароль.
+	keyfile []byte                    // Хеш ключевого файла.
+	secrets map[string]*secmem.Buffer // Ключи шифрования по ID.
 }
 
 var homedir = workdir.Home // для тестов.
@@ -48,14 +61,40 @@ func NewVault() (*Vault, error) {
 		return nil, err
 	}
 
-	v := &Vault{root: root, data: files}
+	v := &Vault{
+		root:    root,
+		data:    files,
+		secrets: make(map[string]*secmem.Buffer),
+	}
 	if err = v.init(); err != nil {
 		return nil, err
 	}
+	if err = v.recover(); err != nil {
+		_ = v.Close()
+		return nil, err
+	}
 
 	return v, nil
 }
 
+// Close заполняет нулями и освобождает память, в которой хранятся
+// мастер-пароль и ключи шифрования хранилища. Ключи, переданные агенту,
+// остаются в нём.
+func (v *Vault) Close() error {
+	v.pass.Destroy()
+	v.pass = nil
+
+	for id, secret := range v.secrets {
+		secret.Destroy()
+		delete(v.secrets, id)
+	}
+
+	secmem.Wipe(v.keyfile)
+	v.keyfile = nil
+
+	return nil
+}
+
 func (v *Vault) init() error {
 	saveOrLoad := func(name string, rw readWriter) error {
 		if v.root.Exists(name) {
@@ -64,12 +103,15 @@ func (v *Vault) init() error {
 		return v.save(name, rw)
 	}
 
-	errc := make(chan error, 2)
+	errc := make(chan error, 5)
 
 	go func() { errc <- saveOrLoad(FilesName, &v.files) }()
+	go func() { errc <- saveOrLoad(KeysName, &v.keys) }()
 	go func() { errc <- saveOrLoad(RemoteName, &v.remote) }()
+	go func() { errc <- saveOrLoad(IdentitiesName, &v.ids) }()
+	go func() { errc <- saveOrLoad(SettingsName, &v.settings) }()
 
-	for i := 0; i < 2; i++ {
+	for i := 0; i < 5; i++ {
 		if err := <-errc; err != nil {
 			return err
 		}
@@ -78,6 +120,15 @@ func (v *Vault) init() error {
 	return nil
 }
 
+// AgentPath возвращает путь к сокету агента ключей.
+func AgentPath() (string, error) {
+	root, err := homedir(DirName)
+	if err != nil {
+		return "", err
+	}
+	return root.Path(AgentName), nil
+}
+
 // SetRemoteAddress устанавливает адрес удалённого сервера.
 func (v *Vault) SetRemoteAddress(address string) error {
 	v.remote.Address = address
@@ -110,143 +161,328 @@ func (v *Vault) Walk(fn func(File) error) error {
 	return nil
 }
 
-// Add добавляет зашифрованный файл в хранилище.
-func (v *Vault) Add(description string, src io.Reader) error {
-	return v.add(description, TypeBinary, src)
+// List возвращает конфигурации всех неудалённых файлов, кроме вложений и
+// ревизий, с расшифрованными типом и описанием.
+func (v *Vault) List() (Files, error) {
+	files := make(Files, 0, len(v.files))
+	for _, file := range v.files {
+		if file.IsDeleted || file.Parent != "" || file.Revises != "" {
+			continue
+		}
+		file, err := v.unseal(file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Add добавляет зашифрованный файл в хранилище. Файл также может быть
+// расшифрован закрытым ключом любого из получателей recipients.
+func (v *Vault) Add(description string, src io.Reader, recipients ...*cryptio.Recipient) error {
+	return v.add(description, TypeBinary, src, recipients)
 }
 
 // AddLoginPassword добавляет зашифрованные данные банковской карты в хранилище.
-func (v *Vault) AddBankCard(description string, card BankCard) error {
+func (v *Vault) AddBankCard(description string, card BankCard, recipients ...*cryptio.Recipient) error {
 	data, err := card.MarshalBinary()
 	if err != nil {
 		return err
 	}
+	defer secmem.Wipe(data)
 	src := bytes.NewReader(data)
-	return v.add(description, TypeCard, src)
+	return v.add(description, TypeCard, src, recipients)
 }
 
 // AddLoginPassword добавляет зашифрованные данные для авторизации пользователя
 // в хранилище.
-func (v *Vault) AddLoginPassword(description string, logpass UsernamePassword) error {
+func (v *Vault) AddLoginPassword(description string, logpass UsernamePassword, recipients ...*cryptio.Recipient) error {
 	data, err := logpass.MarshalBinary()
 	if err != nil {
 		return err
 	}
+	defer secmem.Wipe(data)
 	src := bytes.NewReader(data)
-	return v.add(description, TypeLogpass, src)
+	return v.add(description, TypeLogpass, src, recipients)
+}
+
+// AddNote добавляет зашифрованную текстовую заметку в хранилище.
+func (v *Vault) AddNote(description string, note []byte, recipients ...*cryptio.Recipient) error {
+	if len(bytes.TrimSpace(note)) == 0 {
+		return errors.New("note must not be blank")
+	}
+	return v.add(description, TypeNote, bytes.NewReader(note), recipients)
+}
+
+// Stat возвращает конфигурацию файла с ID id с расшифрованными типом и
+// описанием.
+func (v *Vault) Stat(id string) (File, error) {
+	file, i := v.files.Lookup(id)
+	if i < 0 {
+		return File{}, fmt.Errorf("%s not found", id)
+	}
+	if file.IsDeleted {
+		return File{}, fmt.Errorf("%s has been deleted", id)
+	}
+	return v.unseal(file)
+}
+
+// Update заменяет содержимое файла с ID id содержимым src, сохраняя его тип,
+// описание и получателей. Содержимое шифруется новым ключом файла.
+func (v *Vault) Update(id string, src io.Reader) error {
+	return v.Edit(id, nil, src)
+}
+
+// Edit изменяет файл с ID id, сохраняя его ID, тип и получателей: заменяет
+// описание, если description не nil, и содержимое, если src не nil. Файл
+// всегда перешифровывается новым ключом, а время последнего изменения
+// обновляется, поэтому при синхронизации изменённая версия вытесняет прежнюю.
+// Прежняя версия элемента сохраняется как ревизия, см. Log.
+func (v *Vault) Edit(id string, description *string, src io.Reader) error {
+	return v.edit(id, description, src, true)
+}
+
+// edit изменяет файл с ID id; при revise номер версии элемента, кроме
+// вложений, увеличивается, а прежняя версия сохраняется как ревизия, если
+// ревизии не отключены.
+func (v *Vault) edit(id string, description *string, src io.Reader, revise bool) error {
+	file, err := v.Stat(id)
+	if err != nil {
+		return err
+	}
+	if file.Revises != "" {
+		return fmt.Errorf("%s is a revision of %s and cannot be changed", id, file.Revises)
+	}
+
+	if description != nil && file.Parent != "" {
+		return fmt.Errorf("%s is an attachment, its name cannot be changed", id)
+	}
+
+	revise = revise && file.Parent == ""
+	keep := revise && v.RevisionLimit() > 0
+
+	var rev File
+	var written bool
+	current := file
+	if keep {
+		if rev, err = v.revision(file); err != nil {
+			return err
+		}
+		defer func() {
+			// Если новая версия не записана, то прежняя возвращается на место.
+			if !written {
+				_ = os.Rename(v.data.Path(rev.ID), v.data.Path(id))
+			}
+		}()
+		current = rev
+	}
+	if revise {
+		file.Revision = max(file.Revision, 1) + 1
+		file.Device = v.device()
+	}
+	if description != nil {
+		file.Description = *description
+	}
+
+	if src == nil {
+		var rc io.ReadCloser
+		if rc, err = v.decrypt(current); err != nil {
+			return err
+		}
+		defer rc.Close()
+		src = bufio.NewReader(rc)
+	}
+
+	var key Key
+	if key, err = v.primaryKey(); err != nil {
+		return err
+	}
+
+	if file, err = v.write(file.ID, file, key, src); err != nil {
+		return err
+	}
+	written = true
+
+	_, i := v.files.Lookup(id)
+	v.files[i] = file
+
+	if keep {
+		v.files = append(v.files, rev)
+		v.pruneRevisions(id)
+	}
+
+	return v.save(FilesName, v.files)
+}
+
+func (v *Vault) add(description string, typ Type, src io.Reader, recipients []*cryptio.Recipient) error {
+	file := File{
+		ID:          generateID(v.files.Contains),
+		Revision:    1,
+		Type:        typ,
+		Description: description,
+	}
+
+	for _, r := range recipients {
+		file.Recipients = append(file.Recipients, Recipient{Key: r.String()})
+	}
+
+	return v.insert(file, src)
 }
 
-func (v *Vault) add(description string, typ Type, src io.Reader) error {
-	enc, err := newEncrypter(src)
+// insert шифрует содержимое src и добавляет файл file в хранилище.
+func (v *Vault) insert(file File, src io.Reader) error {
+	key, err := v.primaryKey()
 	if err != nil {
 		return err
 	}
 
-	id := generateID()
+	file.Device = v.device()
 
-	f, err := v.data.Create(id)
+	file, err = v.write(file.ID, file, key, src)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	hw := hashio.NewHashWriter(f)
+	v.files = append(v.files, file)
+
+	return v.save(FilesName, v.files)
+}
+
+// write шифрует содержимое src ключом key, атомарно записывает его в файл
+// name хранилища и возвращает обновлённую конфигурацию файла.
+func (v *Vault) write(name string, file File, key Key, src io.Reader) (File, error) {
+	size := &counter{Reader: src}
+
+	enc, file, err := v.newEncrypter(size, file, key)
+	if err != nil {
+		return File{}, err
+	}
+
+	temp, err := v.data.Temp("temp-*")
+	if err != nil {
+		return File{}, err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.RemoveAll(temp.Name())
+	}()
+
+	hw := hashio.NewHashWriter(temp)
 	buf := bufio.NewWriter(hw)
 
 	if _, err = io.Copy(buf, enc); err != nil {
-		return err
+		return File{}, err
 	}
 	if err = buf.Flush(); err != nil {
-		return err
+		return File{}, err
 	}
 
-	file, i := v.files.Lookup(id)
+	file.Size = int64(size.n)
+	file.KeyID = key.ID
 
-	file.ID = id
-	file.Type = typ
-	file.Description = description
-	file.SHA256 = hw.Checksum()
-	file.Meta = enc.Meta()
-	file.LastUpdate = time.Now().UTC()
+	dek, err := v.fileKey(file)
+	if err != nil {
+		return File{}, err
+	}
+	file.Sealed, err = seal(dek, file)
+	secmem.Wipe(dek)
+	if err != nil {
+		return File{}, err
+	}
 
-	if i < 0 {
-		v.files = append(v.files, file)
-	} else {
-		v.files[i] = file
+	if err = temp.Sync(); err != nil {
+		return File{}, err
+	}
+	if err = temp.Close(); err != nil {
+		return File{}, err
+	}
+	if err = os.Rename(temp.Name(), v.data.Path(name)); err != nil {
+		return File{}, err
 	}
 
-	return v.save(FilesName, v.files)
+	file.SHA256 = hw.Checksum()
+	file.LastUpdate = time.Now().UTC()
+
+	return file, nil
 }
 
 // Get возвращает дешифрованный файл по ID.
-func (v *Vault) Get(id string) (rc io.ReadCloser, err error) {
-	file, i := v.files.Lookup(id)
-	if i < 0 {
-		return nil, fmt.Errorf("%s not found", id)
-	}
-	if file.IsDeleted {
-		return nil, fmt.Errorf("%s has been deleted", id)
-	}
+func (v *Vault) Get(id string) (io.ReadCloser, error) {
+	return v.Show(id, Format{})
+}
 
-	f, err := v.data.Open(id)
+// Show возвращает дешифрованный файл по ID. Данные карт, авторизации и
+// переменные окружения выводятся в текстовом виде format; для остальных
+// типов поддерживается только вид по умолчанию.
+func (v *Vault) Show(id string, format Format) (io.ReadCloser, error) {
+	file, err := v.Stat(id)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			_ = f.Close()
-		}
-	}()
+	if format != (Format{}) && file.Type != TypeCard && file.Type != TypeLogpass && file.Type != TypeEnv {
+		return nil, fmt.Errorf("%s is %s, fields and masking are supported for %s, %s and %s only",
+			id, file.Type, TypeCard, TypeLogpass, TypeEnv)
+	}
 
-	dec, err := newDecrypter(f, file.Meta)
+	rc, err := v.decrypt(file)
 	if err != nil {
 		return nil, err
 	}
 
+	var value secretValue
 	switch file.Type {
 	case TypeCard:
-		b, err := io.ReadAll(dec)
-		if err != nil {
-			return nil, err
-		}
-
-		var card BankCard
+		value = new(BankCard)
+	case TypeLogpass:
+		value = new(UsernamePassword)
+	case TypeTOTP:
+		value = new(otpKey)
+	case TypeEnv:
+		value = new(Env)
+	default:
+		return rc, nil
+	}
+	defer rc.Close()
+	defer value.Wipe()
 
-		if err = card.UnmarshalBinary(b); err != nil {
-			return nil, err
-		}
-		if err = card.Validate(); err != nil {
-			return nil, err
-		}
+	return readSecret(rc, value, format)
+}
 
-		rc = io.NopCloser(strings.NewReader(card.String() + "\n"))
-	case TypeLogpass:
-		b, err := io.ReadAll(dec)
-		if err != nil {
-			return nil, err
-		}
+// decrypt возвращает расшифрованное содержимое file.
+func (v *Vault) decrypt(file File) (io.ReadCloser, error) {
+	f, err := v.data.Open(file.ID)
+	if err != nil {
+		return nil, err
+	}
 
-		var up UsernamePassword
+	dec, err := v.newDecrypter(f, file)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
 
-		if err = up.UnmarshalBinary(b); err != nil {
-			return nil, err
-		}
-		if err = up.Validate(); err != nil {
-			return nil, err
-		}
+	return &decryptCloser{Reader: dec, Closer: f}, nil
+}
 
-		rc = io.NopCloser(strings.NewReader(up.String() + "\n"))
-	default:
-		rc = &decryptCloser{
-			Decrypter: dec,
-			Closer:    f,
-		}
+// unmarshal расшифровывает содержимое file в value.
+func (v *Vault) unmarshal(file File, value encoding.BinaryUnmarshaler) error {
+	src, err := v.decrypt(file)
+	if err != nil {
+		return err
 	}
+	data, err := secmem.ReadAll(src)
+	_ = src.Close()
+	if err != nil {
+		return err
+	}
+	defer data.Destroy()
 
-	return rc, nil
+	return value.UnmarshalBinary(data.Bytes())
 }
 
-// Del удаляет зашифрованный файл из хранилища по id.
+// Del удаляет зашифрованный файл из хранилища по id вместе с его
+// вложениями и ревизиями.
 func (v *Vault) Del(id string) error {
 	file, i := v.files.Lookup(id)
 	if i < 0 {
@@ -256,6 +492,17 @@ func (v *Vault) Del(id string) error {
 		return fmt.Errorf("%s has been deleted", id)
 	}
 
+	for j, child := range v.files {
+		if (child.Parent != id && child.Revises != id) || child.IsDeleted {
+			continue
+		}
+		if err := v.data.Remove(child.ID); err != nil {
+			return err
+		}
+		child.IsDeleted = true
+		v.files[j] = child
+	}
+
 	if err := v.data.Remove(id); err != nil {
 		return err
 	}
@@ -293,7 +540,10 @@ func (v *Vault) Pack() (*os.File, error) {
 	buf := bufio.NewWriter(temp)
 	tw := tar.NewWriter(buf)
 
-	if err = v.packFiles(tw); err != nil {
+	if err = v.packConfig(tw, KeysName); err != nil {
+		return nil, err
+	}
+	if err = v.packConfig(tw, FilesName); err != nil {
 		return nil, err
 	}
 	if err = v.packData(tw); err != nil {
@@ -312,14 +562,14 @@ func (v *Vault) Pack() (*os.File, error) {
 	return temp, nil
 }
 
-func (v *Vault) packFiles(tw *tar.Writer) error {
-	files, err := v.root.Open(FilesName)
+func (v *Vault) packConfig(tw *tar.Writer, name string) error {
+	f, err := v.root.Open(name)
 	if err != nil {
 		return err
 	}
-	defer files.Close()
+	defer f.Close()
 
-	return v.pack(tw, files, "")
+	return v.pack(tw, f, "")
 }
 
 func (v *Vault) packData(tw *tar.Writer) error {
@@ -336,15 +586,20 @@ func (v *Vault) packData(tw *tar.Writer) error {
 		if file.IsDeleted {
 			return nil
 		}
+		return v.packFile(tw, file.ID)
+	})
+}
 
-		f, err := v.data.Open(file.ID)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+// packFile упаковывает зашифрованный файл с ID id в директорию данных
+// архива.
+func (v *Vault) packFile(tw *tar.Writer, id string) error {
+	f, err := v.data.Open(id)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-		return v.pack(tw, f, DataDirName)
-	})
+	return v.pack(tw, f, DataDirName)
 }
 
 func (v *Vault) pack(tw *tar.Writer, f *os.File, baseDir string) error {
@@ -396,11 +651,24 @@ func (v *Vault) Unpack(src io.Reader) error {
 			continue
 		}
 
+		if hdr.Name == KeysName {
+			var keys Keyring
+			if _, err = keys.ReadFrom(io.LimitReader(tr, hdr.Size)); err != nil {
+				return err
+			}
+			v.keys = v.keys.Merge(keys)
+			continue
+		}
+
 		if err = v.unpack(hdr, tr); err != nil {
 			return err
 		}
 	}
 
+	if err := v.save(KeysName, v.keys); err != nil {
+		return err
+	}
+
 	return v.save(FilesName, v.files)
 }
 
@@ -410,13 +678,16 @@ func (v *Vault) unpack(hdr *tar.Header, tr *tar.Reader) error {
 		return nil
 	}
 
-	f, err := v.data.Create(file.ID)
+	temp, err := v.data.Temp("temp-*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer func() {
+		_ = temp.Close()
+		_ = os.RemoveAll(temp.Name())
+	}()
 
-	buf := bufio.NewWriter(f)
+	buf := bufio.NewWriter(temp)
 	hw := hashio.NewHashWriter(buf)
 
 	if _, err = io.CopyN(hw, tr, hdr.Size); err != nil {
@@ -425,12 +696,15 @@ func (v *Vault) unpack(hdr *tar.Header, tr *tar.Reader) error {
 	if err = buf.Flush(); err != nil {
 		return err
 	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
 
 	if file.SHA256 != hw.Checksum() {
 		return fmt.Errorf("chechsum is invalid for %s", file.ID)
 	}
 
-	return nil
+	return os.Rename(temp.Name(), v.data.Path(file.ID))
 }
 
 func (v *Vault) load(name string, r io.ReaderFrom) error {
@@ -447,18 +721,31 @@ func (v *Vault) load(name string, r io.ReaderFrom) error {
 	return nil
 }
 
+// save атомарно сохраняет конфигурацию в файл name.
 func (v *Vault) save(name string, w io.WriterTo) error {
-	f, err := v.root.Create(name)
+	temp, err := v.root.Temp("temp-*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer func() {
+		_ = temp.Close()
+		_ = os.RemoveAll(temp.Name())
+	}()
 
-	buf := bufio.NewWriter(f)
+	buf := bufio.NewWriter(temp)
 
 	if _, err = w.WriteTo(buf); err != nil {
 		return err
 	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
 
-	return buf.Flush()
+	return os.Rename(temp.Name(), v.root.Path(name))
 }
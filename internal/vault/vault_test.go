@@ -2,12 +2,22 @@ package vault
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/sergeizaitcev/gophkeeper/internal/agent"
+	"github.com/sergeizaitcev/gophkeeper/pkg/cryptio"
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
 	"github.com/sergeizaitcev/gophkeeper/pkg/workdir"
 )
 
@@ -17,15 +27,20 @@ func testHomedir(t *testing.T) func(string) (workdir.Dir, error) {
 	}
 }
 
-func testGetpass(_ *testing.T) func() (string, error) {
-	return func() (string, error) {
-		return "password", nil
+func testGetpass(_ *testing.T) func() (*secmem.Buffer, error) {
+	return testPassword("password")
+}
+
+func testPassword(pass string) func() (*secmem.Buffer, error) {
+	return func() (*secmem.Buffer, error) {
+		return secmem.Copy([]byte(pass)), nil
 	}
 }
 
 func TestVault(t *testing.T) {
 	homedir = testHomedir(t)
 	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
 
 	v, err := NewVault()
 	require.NoError(t, err)
@@ -85,3 +100,1256 @@ func TestVault(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 3, n)
 }
+
+func TestVault_Migrate(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	want := []byte("some data")
+
+	enc, err := cryptio.NewEncrypter(bytes.NewReader(want), cryptio.LegacyKey([]byte("password")))
+	require.NoError(t, err)
+
+	f, err := v.data.Create("legacy")
+	require.NoError(t, err)
+	_, err = io.Copy(f, enc)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	v.files = append(v.files, File{ID: "legacy", Type: TypeBinary, Description: "legacy description", Meta: enc.Meta()})
+	require.NoError(t, v.save(FilesName, v.files))
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.Equal(t, "legacy description", v.files[0].Description)
+
+	legacy, err := os.ReadFile(v.data.Path("legacy"))
+	require.NoError(t, err)
+
+	n, err := v.Migrate()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// Пароль нельзя проверить по произвольным данным, поэтому копия
+	// прежнего файла сохраняется.
+	require.NotEmpty(t, v.LegacyCopies())
+	kept, err := os.ReadFile(filepath.Join(v.LegacyCopies(), "legacy"))
+	require.NoError(t, err)
+	require.Equal(t, legacy, kept)
+
+	raw, err := os.ReadFile(v.root.Path(FilesName))
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "legacy description")
+
+	primary, ok := v.keys.Primary()
+	require.True(t, ok)
+
+	file, _ := v.files.Lookup("legacy")
+	require.Equal(t, primary.ID, file.KeyID)
+	require.False(t, isLegacy(file))
+
+	rc, err := v.Get("legacy")
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	n, err = v.Migrate()
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+// addLegacy добавляет в хранилище файл, зашифрованный устаревшим способом
+// в формате AES-CTR ключом из пароля pass.
+func addLegacy(t *testing.T, v *Vault, id string, typ Type, data []byte, pass string) {
+	enc, err := cryptio.NewEncrypter(bytes.NewReader(data), cryptio.LegacyKey([]byte(pass)))
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(enc)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(v.data.Path(id), b, workdir.FileMode))
+
+	sum := sha256.Sum256(b)
+	v.files = append(v.files, File{ID: id, Type: typ, SHA256: hex.EncodeToString(sum[:]), Meta: enc.Meta()})
+	require.NoError(t, v.save(FilesName, v.files))
+}
+
+func TestVault_LegacyPassword(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testPassword("other")
+
+	v, err := NewVault()
+	require.NoError(t, err)
+	addLegacy(t, v, "card", TypeCard, []byte("4720475535629559"), "password")
+
+	getpass = testPassword("wrong")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	_, err = v.Get("card")
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	// Основной ключ не создаётся паролем, отличным от пароля прежних файлов.
+	require.ErrorIs(t, v.Add("description", bytes.NewReader([]byte("some data"))), ErrWrongPassword)
+	require.Empty(t, v.keys)
+
+	getpass = testGetpass(t)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	card, err := v.BankCard("card")
+	require.NoError(t, err)
+	require.Equal(t, "4720475535629559", string(card.Number))
+
+	require.NoError(t, v.Add("description", bytes.NewReader([]byte("some data"))))
+
+	primary, ok := v.keys.Primary()
+	require.True(t, ok)
+	require.NoError(t, v.verify(primary))
+}
+
+func TestVault_LegacyPasswordUnverified(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+	addLegacy(t, v, "binary", TypeBinary, []byte("some data"), "password")
+
+	// Произвольные данные не позволяют проверить пароль, поэтому перед
+	// созданием основного ключа он запрашивается повторно.
+	passwords := []string{"password", "typo"}
+	getpass = func() (*secmem.Buffer, error) {
+		pass := passwords[0]
+		passwords = passwords[1:]
+		return secmem.Copy([]byte(pass)), nil
+	}
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.EqualError(t, v.Add("description", bytes.NewReader([]byte("other data"))), "passwords do not match")
+	require.Empty(t, v.keys)
+
+	getpass = testGetpass(t)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.NoError(t, v.Add("description", bytes.NewReader([]byte("other data"))))
+}
+
+func TestVault_MigrateWrongPassword(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+	addLegacy(t, v, "card", TypeCard, []byte("4720475535629559"), "password")
+
+	legacy, err := os.ReadFile(v.data.Path("card"))
+	require.NoError(t, err)
+
+	getpass = testPassword("wrong")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	_, err = v.Migrate()
+	require.ErrorIs(t, err, ErrWrongPassword)
+	require.Empty(t, v.keys)
+
+	got, err := os.ReadFile(v.data.Path("card"))
+	require.NoError(t, err)
+	require.Equal(t, legacy, got)
+
+	getpass = testGetpass(t)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	n, err := v.Migrate()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Empty(t, v.LegacyCopies())
+
+	card, err := v.BankCard("card")
+	require.NoError(t, err)
+	require.Equal(t, "4720475535629559", string(card.Number))
+}
+
+func TestVault_Tampered(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	err = v.Add("description", bytes.NewReader([]byte("some data")))
+	require.NoError(t, err)
+
+	id := v.files[0].ID
+
+	b, err := os.ReadFile(v.data.Path(id))
+	require.NoError(t, err)
+
+	b[len(b)-1] ^= 1
+	require.NoError(t, os.WriteFile(v.data.Path(id), b, workdir.FileMode))
+
+	rc, err := v.Get(id)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	_, err = io.ReadAll(rc)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+}
+
+func TestVault_WrongPassword(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	err = v.Add("description", bytes.NewReader([]byte("some data")))
+	require.NoError(t, err)
+
+	getpass = testPassword("wrong")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	_, err = v.Get(v.files[0].ID)
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	err = v.Add("description", bytes.NewReader([]byte("other data")))
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	_, err = v.NewKey(0, 0, 0)
+	require.ErrorIs(t, err, ErrWrongPassword)
+}
+
+func TestVault_ChangePassword(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	want := []byte("some data")
+
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+
+	before := v.files.Clone()
+
+	_, err = v.ChangePassword(nil)
+	require.Error(t, err)
+
+	getnewpass = testPassword("new password")
+
+	n, err := v.ChangePassword(nil)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, before, v.files)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	_, err = v.Get(v.files[0].ID)
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	getpass = testPassword("new password")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	rc, err := v.Get(v.files[0].ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestVault_Recover(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	require.NoError(t, v.Add("description", bytes.NewReader([]byte("some data"))))
+
+	file := v.files[0]
+
+	primary, ok := v.keys.Primary()
+	require.True(t, ok)
+
+	kdf, err := cryptio.NewKDF(1, 64, 1)
+	require.NoError(t, err)
+
+	key, err := v.newKey(kdf, []byte("new password"))
+	require.NoError(t, err)
+
+	// Прерывание до фиксации журнала: перешифрование откатывается.
+	_, err = v.reencrypt(file, key)
+	require.NoError(t, err)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.False(t, v.data.Exists(file.ID+pendingExt))
+	require.Equal(t, primary.ID, v.files[0].KeyID)
+
+	// Прерывание после фиксации журнала: перешифрование завершается.
+	key, err = v.newKey(kdf, []byte("new password"))
+	require.NoError(t, err)
+
+	updated, err := v.reencrypt(file, key)
+	require.NoError(t, err)
+	require.NoError(t, v.save(JournalName, journal{Key: key, Files: Files{updated}}))
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.False(t, v.root.Exists(JournalName))
+	require.Equal(t, key.ID, v.files[0].KeyID)
+
+	getpass = testPassword("new password")
+
+	rc, err := v.Get(file.ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, []byte("some data"), got)
+}
+
+func TestVault_Rotate(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	want := []byte("some data")
+
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+
+	before := v.files.Clone()
+
+	n, err := v.Rotate(before[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	require.NotEqual(t, before[0].Meta, v.files[0].Meta)
+	require.NotEqual(t, before[0].SHA256, v.files[0].SHA256)
+	require.Equal(t, before[1], v.files[1])
+
+	rc, err := v.Get(before[0].ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	_, err = v.Rotate("unknown")
+	require.Error(t, err)
+}
+
+func TestVault_Agent(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	ln, err := agent.Listen(dir.Path(AgentName))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() { _ = agent.New(0).Serve(ln) }()
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	want := []byte("some data")
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+
+	getpass = func() (*secmem.Buffer, error) { return nil, errors.New("password must not be requested") }
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	rc, err := v.Get(v.files[0].ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	require.NoError(t, agent.NewClient(dir.Path(AgentName)).Lock())
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	_, err = v.Get(v.files[0].ID)
+	require.Error(t, err)
+}
+
+func TestVault_Recipients(t *testing.T) {
+	aliceDir := workdir.Dir(t.TempDir())
+	bobDir := workdir.Dir(t.TempDir())
+
+	open := func(dir workdir.Dir, pass string) *Vault {
+		homedir = func(string) (workdir.Dir, error) { return dir, nil }
+		getpass = testPassword(pass)
+		getnewpass = getpass
+
+		v, err := NewVault()
+		require.NoError(t, err)
+
+		return v
+	}
+
+	bob := open(bobDir, "bob")
+
+	identity, err := bob.GenerateIdentity()
+	require.NoError(t, err)
+	require.Len(t, bob.Identities(), 1)
+	require.Error(t, bob.ImportIdentity(identity))
+
+	alice := open(aliceDir, "alice")
+
+	want := []byte("some data")
+	require.NoError(t, alice.Add("description", bytes.NewReader(want), identity.Recipient()))
+
+	file := alice.files[0]
+	require.Len(t, file.Recipients, 1)
+	require.Equal(t, identity.Recipient().String(), file.Recipients[0].Key)
+
+	archive, err := alice.Export(file.ID)
+	require.NoError(t, err)
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	// Хранилище получателя уже содержит больше файлов, чем архив.
+	bob = open(bobDir, "bob")
+	require.NoError(t, bob.Add("first", bytes.NewReader([]byte("first"))))
+	require.NoError(t, bob.Add("second", bytes.NewReader([]byte("second"))))
+
+	n, err := bob.Import(archive)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	bob = open(bobDir, "bob")
+
+	files, err := bob.List()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	rc, err := bob.Get(file.ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	n, err = bob.Migrate()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	rc, err = bob.Get(file.ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestVault_SealedIndex(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	require.NoError(t, v.AddLoginPassword("prod database root", NewUsernamePassword("root", "pass")))
+
+	raw, err := os.ReadFile(v.root.Path(FilesName))
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "prod database root")
+	require.NotContains(t, string(raw), `"size"`)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.Empty(t, v.files[0].Description)
+	require.Zero(t, v.files[0].Size)
+
+	files, err := v.List()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, TypeLogpass, files[0].Type)
+	require.Equal(t, "prod database root", files[0].Description)
+	require.Positive(t, files[0].Size)
+
+	file := v.files[0]
+	file.Sealed[len(file.Sealed)-1] ^= 1
+
+	_, err = v.List()
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+}
+
+func TestVault_Note(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	require.Error(t, v.AddNote("empty", []byte(" \n")))
+	require.NoError(t, v.AddNote("wifi", []byte("ssid: home\npassword: secret\n")))
+
+	id := v.files[0].ID
+	lastUpdate := v.files[0].LastUpdate
+
+	require.NoError(t, v.Update(id, bytes.NewReader([]byte("ssid: home\npassword: changed\n"))))
+	files, err := v.List()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.True(t, v.files[0].LastUpdate.After(lastUpdate))
+
+	file, err := v.Stat(id)
+	require.NoError(t, err)
+	require.Equal(t, TypeNote, file.Type)
+	require.Equal(t, "wifi", file.Description)
+
+	rc, err := v.Get(id)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "ssid: home\npassword: changed\n", string(got))
+
+	require.NoError(t, v.Del(id))
+	require.Error(t, v.Update(id, bytes.NewReader([]byte("note"))))
+}
+
+func TestVault_OTP(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	totp, err := otp.Parse("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8")
+	require.NoError(t, err)
+	require.NoError(t, v.AddOTP("github", totp))
+	totpID := v.files[0].ID
+
+	code, err := v.OTP(totpID, time.Unix(59, 0))
+	require.NoError(t, err)
+	require.Equal(t, otp.TOTP, code.Kind)
+	require.Equal(t, "94287082", code.Code)
+	require.Equal(t, time.Second, code.Remaining)
+
+	hotp, err := otp.Parse("otpauth://hotp/bob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0")
+	require.NoError(t, err)
+	require.NoError(t, v.AddOTP("vpn", hotp))
+	hotpID := v.files[1].ID
+
+	for counter, want := range []string{"755224", "287082", "359152"} {
+		code, err = v.OTP(hotpID, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, otp.HOTP, code.Kind)
+		require.Equal(t, uint64(counter), code.Counter)
+		require.Equal(t, want, code.Code)
+	}
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	code, err = v.OTP(hotpID, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), code.Counter)
+	require.Equal(t, "969429", code.Code)
+
+	require.NoError(t, v.AddNote("note", []byte("text")))
+	_, err = v.OTP(v.files[2].ID, time.Now())
+	require.Error(t, err)
+}
+
+func TestVault_Attachments(t *testing.T) {
+	open := func(dir workdir.Dir) *Vault {
+		homedir = func(string) (workdir.Dir, error) { return dir, nil }
+		getpass = testGetpass(t)
+		getnewpass = testGetpass(t)
+
+		v, err := NewVault()
+		require.NoError(t, err)
+
+		return v
+	}
+
+	laptop := open(workdir.Dir(t.TempDir()))
+
+	require.NoError(t, laptop.AddLoginPassword("bank", NewUsernamePassword("user", "pass")))
+	id := laptop.files[0].ID
+
+	want := []byte("%PDF recovery codes")
+	require.NoError(t, laptop.Attach(id, "recovery.pdf", bytes.NewReader(want)))
+	require.Error(t, laptop.Attach(id, "recovery.pdf", bytes.NewReader(want)))
+	require.Error(t, laptop.Attach(id, "../cert.pem", bytes.NewReader(want)))
+	require.Error(t, laptop.Attach(laptop.files[1].ID, "cert.pem", bytes.NewReader(want)))
+
+	files, err := laptop.List()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	attachments, err := laptop.Attachments(id)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	require.Equal(t, "recovery.pdf", attachments[0].Description)
+
+	archive, err := laptop.Pack()
+	require.NoError(t, err)
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	phone := open(workdir.Dir(t.TempDir()))
+	require.NoError(t, phone.Unpack(archive))
+
+	rc, err := phone.Attachment(id, "recovery.pdf")
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	_, err = phone.Attachment(id, "cert.pem")
+	require.Error(t, err)
+
+	require.NoError(t, laptop.Del(id))
+	require.False(t, laptop.data.Exists(attachments[0].ID))
+	_, err = laptop.Stat(attachments[0].ID)
+	require.Error(t, err)
+}
+
+func TestVault_Env(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	env, err := ParseEnv([]byte("DB_HOST=db.internal\nDB_PASSWORD=secret\n"))
+	require.NoError(t, err)
+	require.NoError(t, v.AddEnv("prod", env))
+	require.Error(t, v.AddEnv("empty", nil))
+
+	id := v.files[0].ID
+
+	got, err := v.Env(id)
+	require.NoError(t, err)
+	require.Equal(t, env, got)
+	require.Equal(t, []string{"DB_HOST=db.internal", "DB_PASSWORD=secret"}, got.Environ(nil))
+
+	rc, err := v.Show(id, Format{Field: "DB_PASSWORD"})
+	require.NoError(t, err)
+	defer rc.Close()
+
+	text, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "secret\n", string(text))
+
+	require.NoError(t, v.AddNote("note", []byte("text")))
+	_, err = v.Env(v.files[1].ID)
+	require.Error(t, err)
+}
+
+func TestVault_Audit(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	require.NoError(t, v.AddLoginPassword("weak", NewUsernamePassword("user", "Password1!")))
+	require.NoError(t, v.AddLoginPassword("mail", NewUsernamePassword("user", "Q2%9gT{rM#fWy.p8EyOx")))
+	require.NoError(t, v.AddLoginPassword("bank", NewUsernamePassword("user", "Q2%9gT{rM#fWy.p8EyOx")))
+	require.NoError(t, v.AddLoginPassword("unique", NewUsernamePassword("user", "x7]Lq#2vN!pR8m@Kc4zB")))
+
+	card := NewBankCard("4720 4755 3562 9559")
+	card.Expiry = Expiry{Month: 1, Year: 2020}
+	require.NoError(t, v.AddBankCard("old card", card))
+	require.NoError(t, v.AddNote("old note", []byte("text")))
+
+	weak, mail, bank, unique, expired, stale := v.files[0].ID, v.files[1].ID, v.files[2].ID, v.files[3].ID, v.files[4].ID, v.files[5].ID
+	v.files[5].LastUpdate = now.Add(-400 * 24 * time.Hour)
+
+	report, err := v.Audit(AuditOptions{Now: now, MaxAge: DefaultMaxAge, MinEntropy: DefaultMinEntropy})
+	require.NoError(t, err)
+	require.Equal(t, 6, report.Items)
+
+	issues := make(map[string][]string)
+	for _, f := range report.Findings {
+		issues[f.ID] = append(issues[f.ID], f.Issue)
+	}
+
+	require.Equal(t, []string{IssueWeak}, issues[weak])
+	require.Equal(t, []string{IssueReused}, issues[mail])
+	require.Equal(t, []string{IssueReused}, issues[bank])
+	require.Empty(t, issues[unique])
+	require.Equal(t, []string{IssueExpired}, issues[expired])
+	require.Equal(t, []string{IssueStale}, issues[stale])
+}
+
+func TestVault_KeyFile(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+	keyfile := filepath.Join(t.TempDir(), "vault.key")
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+	require.NoError(t, v.Init(keyfile))
+	require.FileExists(t, keyfile)
+	require.Error(t, v.Init(""))
+
+	want := []byte("some data")
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+
+	get := func() ([]byte, error) {
+		v, err := NewVault()
+		require.NoError(t, err)
+
+		rc, err := v.Get(v.files[0].ID)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		return io.ReadAll(rc)
+	}
+
+	got, err := get()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.NoError(t, v.save(SettingsName, Settings{}))
+
+	_, err = get()
+	require.ErrorIs(t, err, ErrKeyFileRequired)
+
+	other := filepath.Join(t.TempDir(), "other.key")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0o600))
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.ErrorIs(t, v.Init(other), ErrWrongPassword)
+	require.NoError(t, v.Init(keyfile))
+
+	got, err = get()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	getnewpass = testPassword("new password")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	noKeyFile := ""
+	_, err = v.ChangePassword(&noKeyFile)
+	require.NoError(t, err)
+
+	getpass = getnewpass
+
+	got, err = get()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestVault_RecoverPassword(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+	keyfile := filepath.Join(t.TempDir(), "vault.key")
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+	require.NoError(t, v.Init(keyfile))
+
+	want := []byte("some data")
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+
+	primary, ok := v.keys.Primary()
+	require.True(t, ok)
+
+	secret, err := v.secret(primary.ID)
+	require.NoError(t, err)
+
+	kdf, err := cryptio.NewKDF(1, 64, 1)
+	require.NoError(t, err)
+
+	key, err := wrapKey(primary, kdf, []byte("new password"), nil, secret)
+	require.NoError(t, err)
+	key.UpdatedAt = time.Now().UTC()
+
+	// Прерывание после фиксации журнала смены пароля без ключевого файла:
+	// ключи и настройки применяются вместе.
+	require.NoError(t, v.save(JournalName, journal{Keys: Keyring{key}, Settings: &Settings{}}))
+
+	getpass = testPassword("new password")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+	require.False(t, v.root.Exists(JournalName))
+	require.Empty(t, v.settings.KeyFile)
+
+	rc, err := v.Get(v.files[0].ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestVault_RecoveryKit(t *testing.T) {
+	dir := workdir.Dir(t.TempDir())
+
+	homedir = func(string) (workdir.Dir, error) { return dir, nil }
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	want := []byte("some data")
+	require.NoError(t, v.Add("description", bytes.NewReader(want)))
+
+	secret, err := v.RecoverySecret()
+	require.NoError(t, err)
+
+	getpass = func() (*secmem.Buffer, error) { return nil, errors.New("password is forgotten") }
+	getnewpass = testPassword("new password")
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	corrupted := bytes.Clone(secret)
+	corrupted[2] ^= 1
+	_, err = v.Recover(corrupted, nil)
+	require.Error(t, err)
+
+	_, err = v.Recover(secret, nil)
+	require.NoError(t, err)
+
+	getpass = getnewpass
+
+	v, err = NewVault()
+	require.NoError(t, err)
+
+	rc, err := v.Get(v.files[0].ID)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func Test_generateID(t *testing.T) {
+	defer func(r io.Reader) { randutil.Reader = r }(randutil.Reader)
+
+	// Первые два ID совпадают с существующими файлами, третий свободен.
+	randutil.Reader = bytes.NewReader([]byte{
+		0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+		0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+		0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	})
+
+	files := Files{{ID: "010101010101"}, {ID: "020202020202", IsDeleted: true}}
+	require.Equal(t, "030303030303", generateID(files.Contains))
+}
+
+// breachDB определяет базу скомпрометированных паролей для тестов.
+type breachDB map[string]int
+
+func (db breachDB) Count(password []byte) (int, error) {
+	return db[string(password)], nil
+}
+
+func TestVault_CheckBreaches(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	require.NoError(t, v.AddLoginPassword("breached", NewUsernamePassword("user", "password")))
+	require.NoError(t, v.AddLoginPassword("safe", NewUsernamePassword("user", "x7]Lq#2vN!pR8m@Kc4zB")))
+	require.NoError(t, v.AddNote("note", []byte("password")))
+
+	findings, err := v.CheckBreaches(breachDB{"password": 42})
+	require.NoError(t, err)
+	require.Equal(t, []Finding{{
+		ID:          v.files[0].ID,
+		Type:        TypeLogpass.String(),
+		Description: "breached",
+		Issue:       IssueBreached,
+		Detail:      "seen 42 times in breaches",
+	}}, findings)
+}
+
+func TestVault_LoginHistory(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+	require.Equal(t, DefaultHistoryLimit, v.HistoryLimit())
+
+	require.NoError(t, v.AddLoginPassword("mail", NewUsernamePassword("user", "first")))
+	id := v.files[0].ID
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, v.ChangeLoginPassword(id, []byte("second"), now))
+	require.Error(t, v.ChangeLoginPassword(id, []byte("second"), now))
+	require.NoError(t, v.ChangeLoginPassword(id, []byte("third"), now.Add(time.Hour)))
+
+	up, err := v.Login(id)
+	require.NoError(t, err)
+	require.Equal(t, "third", string(up.Password))
+	require.Equal(t, []PreviousPassword{
+		{Password: []byte("second"), ChangedAt: now.Add(time.Hour)},
+		{Password: []byte("first"), ChangedAt: now},
+	}, up.History)
+
+	// Изменение других полей не затрагивает историю, а история из up
+	// игнорируется.
+	up.Notes = "notes"
+	up.History = nil
+	require.NoError(t, v.UpdateLogin(id, nil, up, now))
+	up, err = v.Login(id)
+	require.NoError(t, err)
+	require.Equal(t, "notes", up.Notes)
+	require.Len(t, up.History, 2)
+
+	require.NoError(t, v.SetHistoryLimit(1))
+	require.NoError(t, v.ChangeLoginPassword(id, []byte("fourth"), now.Add(2*time.Hour)))
+	up, err = v.Login(id)
+	require.NoError(t, err)
+	require.Equal(t, []PreviousPassword{
+		{Password: []byte("third"), ChangedAt: now.Add(2 * time.Hour)},
+	}, up.History)
+
+	require.Error(t, v.SetHistoryLimit(-1))
+	require.NoError(t, v.AddNote("note", []byte("text")))
+	_, err = v.Login(v.files[len(v.files)-1].ID)
+	require.Error(t, err)
+}
+
+func TestVault_Edit(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	card := NewBankCard("4720 4755 3562 9559")
+	card.Holder = "John Doe"
+	require.NoError(t, v.AddBankCard("card", card))
+	require.NoError(t, v.Attach(v.files[0].ID, "scan.png", bytes.NewReader([]byte("png"))))
+	require.NoError(t, v.AddEnv("env", Env{{Name: "A", Value: []byte("1")}}))
+
+	id, attachment, envID := v.files[0].ID, v.files[1].ID, v.files[2].ID
+	before := v.files[0]
+
+	description := "salary card"
+	require.NoError(t, v.Edit(id, &description, nil))
+
+	file, err := v.Stat(id)
+	require.NoError(t, err)
+	require.Equal(t, description, file.Description)
+	require.Equal(t, TypeCard, file.Type)
+	require.True(t, file.LastUpdate.After(before.LastUpdate))
+	require.NotEqual(t, before.SHA256, file.SHA256)
+
+	card, err = v.BankCard(id)
+	require.NoError(t, err)
+	require.Equal(t, "John Doe", card.Holder)
+
+	card.Holder = "Jane Doe"
+	require.NoError(t, v.UpdateBankCard(id, nil, card))
+	card, err = v.BankCard(id)
+	require.NoError(t, err)
+	require.Equal(t, "Jane Doe", card.Holder)
+	files, err := v.List()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// Вложения остаются при изменении элемента, но их имена не меняются.
+	attachments, err := v.Attachments(id)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	require.Error(t, v.Edit(attachment, &description, nil))
+	require.NoError(t, v.Edit(attachment, nil, bytes.NewReader([]byte("jpg"))))
+
+	require.NoError(t, v.UpdateEnv(envID, nil, Env{{Name: "B", Value: []byte("2")}}))
+	env, err := v.Env(envID)
+	require.NoError(t, err)
+	require.Equal(t, Env{{Name: "B", Value: []byte("2")}}, env)
+
+	totp, err := otp.Parse("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	require.NoError(t, err)
+	require.NoError(t, v.AddOTP("github", totp))
+	otpID := v.files[len(v.files)-1].ID
+
+	key, err := v.OTPKey(otpID)
+	require.NoError(t, err)
+	key.Issuer, key.Digits = "GitHub", 8
+	key.Secret, err = otp.DecodeSecret("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	otpDescription := "github 2fa"
+	require.NoError(t, v.UpdateOTP(otpID, &otpDescription, key))
+
+	edited, err := v.OTPKey(otpID)
+	require.NoError(t, err)
+	require.Equal(t, key, edited)
+	file, err = v.Stat(otpID)
+	require.NoError(t, err)
+	require.Equal(t, otpDescription, file.Description)
+
+	key.Digits = 4
+	require.Error(t, v.UpdateOTP(otpID, nil, key))
+	require.Error(t, v.UpdateOTP(id, nil, edited))
+	_, err = v.OTPKey(id)
+	require.Error(t, err)
+
+	require.Error(t, v.UpdateEnv(id, nil, env))
+	require.Error(t, v.UpdateBankCard(envID, nil, card))
+	require.Error(t, v.UpdateNote(id, nil, []byte("note")))
+
+	// При синхронизации изменённая версия вытесняет прежнюю с тем же ID.
+	current, _ := v.files.Lookup(id)
+	stale := Files{before}
+	require.Equal(t, Files{current}, stale.Merge(Files{current}))
+	require.Equal(t, Files{current}, Files{current}.Merge(stale))
+}
+
+func TestVault_Revisions(t *testing.T) {
+	homedir = testHomedir(t)
+	getpass = testGetpass(t)
+	getnewpass = testGetpass(t)
+
+	v, err := NewVault()
+	require.NoError(t, err)
+
+	read := func(id string) string {
+		rc, err := v.Get(id)
+		require.NoError(t, err)
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	require.NoError(t, v.AddNote("wifi", []byte("first")))
+	id := v.files[0].ID
+
+	description := "home wifi"
+	require.NoError(t, v.Update(id, bytes.NewReader([]byte("second!"))))
+	require.NoError(t, v.Edit(id, &description, bytes.NewReader([]byte("third"))))
+
+	log, err := v.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+	for i, want := range []struct {
+		revision    int
+		description string
+		content     string
+	}{
+		{3, "home wifi", "third"},
+		{2, "wifi", "second!"},
+		{1, "wifi", "first"},
+	} {
+		require.Equal(t, want.revision, log[i].Revision)
+		require.Equal(t, want.description, log[i].Description)
+		require.Equal(t, TypeNote, log[i].Type)
+		require.Equal(t, v.device(), log[i].Device)
+		require.Equal(t, int64(len(want.content)), log[i].Size)
+		require.Equal(t, want.content, read(log[i].ID))
+	}
+	require.Equal(t, id, log[0].ID)
+
+	err = v.Attach(log[1].ID, "photo.jpg", bytes.NewReader([]byte("jpeg")))
+	require.ErrorContains(t, err, "is a revision of")
+	attachments, err := v.Attachments(id)
+	require.NoError(t, err)
+	require.Empty(t, attachments)
+
+	// Ревизии не видны в списке и не изменяются.
+	files, err := v.List()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Error(t, v.Update(log[1].ID, bytes.NewReader([]byte("x"))))
+	_, err = v.Log(log[1].ID)
+	require.Error(t, err)
+
+	require.NoError(t, v.Restore(id, 1))
+	require.Equal(t, "first", read(id))
+	log, err = v.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 4)
+	require.Equal(t, 4, log[0].Revision)
+	require.Equal(t, "wifi", log[0].Description)
+	require.Equal(t, "third", read(log[1].ID))
+
+	require.Error(t, v.Restore(id, 4))
+	require.Error(t, v.Restore(id, 10))
+
+	// Лишние ревизии удаляются сразу после изменения ограничения.
+	require.NoError(t, v.SetRevisionLimit(1))
+	log, err = v.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.Equal(t, 3, log[1].Revision)
+	require.Equal(t, 2, len(v.files)-countLive(v.files))
+	require.NoError(t, v.Update(id, bytes.NewReader([]byte("fifth"))))
+	log, err = v.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.Equal(t, "first", read(log[1].ID))
+
+	require.NoError(t, v.SetRevisionLimit(0))
+	require.NoError(t, v.Update(id, bytes.NewReader([]byte("sixth"))))
+	log, err = v.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	require.Equal(t, 6, log[0].Revision)
+
+	require.NoError(t, v.Del(id))
+	require.Zero(t, countLive(v.files))
+}
+
+// countLive возвращает количество неудалённых файлов.
+func countLive(files Files) int {
+	var n int
+	for _, file := range files {
+		if !file.IsDeleted {
+			n++
+		}
+	}
+	return n
+}
+
+func TestVault_RevisionsSync(t *testing.T) {
+	open := func(dir workdir.Dir) *Vault {
+		homedir = func(string) (workdir.Dir, error) { return dir, nil }
+		getpass = testGetpass(t)
+		getnewpass = testGetpass(t)
+
+		v, err := NewVault()
+		require.NoError(t, err)
+
+		return v
+	}
+	sync := func(dst, src *Vault) {
+		archive, err := src.Pack()
+		require.NoError(t, err)
+		defer os.Remove(archive.Name())
+		defer archive.Close()
+		require.NoError(t, dst.Unpack(archive))
+	}
+	read := func(v *Vault, id string) string {
+		rc, err := v.Get(id)
+		require.NoError(t, err)
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	laptop := open(workdir.Dir(t.TempDir()))
+	require.NoError(t, laptop.AddNote("wifi", []byte("password: secret")))
+	id := laptop.files[0].ID
+
+	phone := open(workdir.Dir(t.TempDir()))
+	sync(phone, laptop)
+
+	// Неудачная правка на ноутбуке откатывается с телефона.
+	require.NoError(t, laptop.Update(id, bytes.NewReader([]byte("oops"))))
+	sync(phone, laptop)
+	require.Equal(t, "oops", read(phone, id))
+
+	log, err := phone.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.NoError(t, phone.Restore(id, 1))
+
+	sync(laptop, phone)
+	require.Equal(t, "password: secret", read(laptop, id))
+
+	log, err = laptop.Log(id)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+	require.Equal(t, 3, log[0].Revision)
+	require.Equal(t, "oops", read(laptop, log[1].ID))
+}
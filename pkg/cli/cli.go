@@ -16,10 +16,12 @@ type Commander interface {
 
 // Command определяет основную команду выполнения.
 type Command struct {
-	Name        string      // Наименование команды.
-	Description string      // Описание команды.
-	Version     string      // Версия команды.
-	Subcommands []Commander // Список подкоманд для выполнения.
+	Name        string              // Наименование команды.
+	Description string              // Описание команды.
+	Version     string              // Версия команды.
+	Flags       func(*flag.FlagSet) // Функция регистрации общих флагов.
+	Prepare     func() error        // Функция, вызываемая после разбора флагов.
+	Subcommands []Commander         // Список подкоманд для выполнения.
 }
 
 // CommandGroup определяет командную группу.
@@ -39,12 +41,27 @@ type Subcommand struct {
 
 // Execute запускает выполнение команды.
 func (cmd *Command) Execute() error {
-	if len(os.Args) < 2 {
-		cmd.usage()
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.Usage = func() {}
+
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Println()
+		}
+		cmd.usage(fs)
+		return nil
+	}
+
+	args := fs.Args()
+	if len(args) == 0 {
+		cmd.usage(fs)
 		return nil
 	}
 
-	name := os.Args[1]
+	name := args[0]
 	if name == "version" {
 		fmt.Printf("Version: %s\n", cmd.Version)
 		return nil
@@ -52,11 +69,17 @@ func (cmd *Command) Execute() error {
 
 	sub, ok := lookup(name, cmd.Subcommands)
 	if !ok {
-		cmd.usage()
+		cmd.usage(fs)
 		return nil
 	}
 
-	return sub.run(cmd.Name, os.Args[2:])
+	if cmd.Prepare != nil {
+		if err := cmd.Prepare(); err != nil {
+			return err
+		}
+	}
+
+	return sub.run(cmd.Name, args[1:])
 }
 
 func (group *CommandGroup) run(parent string, args []string) error {
@@ -112,15 +135,23 @@ func (sub *Subcommand) equal(name string) bool {
 	return sub.Name == name
 }
 
-func (cmd *Command) usage() {
+func (cmd *Command) usage(fs *flag.FlagSet) {
 	if cmd.Description != "" {
 		fmt.Printf("Description: %s\n\n", cmd.Description)
 	}
-	fmt.Printf("Usage: %s [version] | <command> ...\n\n", cmd.Name)
+	if cmd.Flags == nil {
+		fmt.Printf("Usage: %s [version] | <command> ...\n\n", cmd.Name)
+	} else {
+		fmt.Printf("Usage: %s [version] | [<flags>] <command> ...\n\n", cmd.Name)
+	}
 	fmt.Print("List of commands:\n")
 	for _, sub := range cmd.Subcommands {
 		sub.shortPrint()
 	}
+	if cmd.Flags != nil {
+		fmt.Print("\nList of flags:\n")
+		fs.PrintDefaults()
+	}
 }
 
 func (group *CommandGroup) usage(parent string) {
@@ -0,0 +1,134 @@
+package cliutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// Edit открывает редактор пользователя на временном файле name с
+// содержимым content и возвращает отредактированное содержимое.
+//
+// Редактор определяется переменными окружения VISUAL и EDITOR. Временный
+// файл создаётся в закрытой директории в оперативной памяти, если она
+// доступна, а после завершения редактора перезаписывается нулями и
+// удаляется вместе с резервными копиями редактора.
+func Edit(name string, content []byte) (_ *secmem.Buffer, err error) {
+	dir, err := os.MkdirTemp(privateTempDir(), "gk-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if shredErr := shred(dir); err == nil {
+			err = shredErr
+		}
+	}()
+
+	path := filepath.Join(dir, filepath.Base(name))
+	if err = os.WriteFile(path, content, 0o600); err != nil {
+		return nil, err
+	}
+
+	cmd := editorCommand(path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return secmem.ReadAll(f)
+}
+
+// editorCommand возвращает команду запуска редактора для файла path.
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if runtime.GOOS == "windows" {
+		if editor == "" {
+			editor = "notepad"
+		}
+		return exec.Command("cmd", "/C", editor, path)
+	}
+
+	if editor == "" {
+		editor = "vi"
+	}
+	// Путь передаётся аргументом, чтобы не экранировать его для оболочки;
+	// сама переменная может содержать аргументы редактора.
+	return exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+}
+
+// privateTempDir возвращает директорию для временных файлов с секретами:
+// XDG_RUNTIME_DIR или /dev/shm, которые размещаются в оперативной памяти.
+// Если они недоступны, то используется os.TempDir и выводится
+// предупреждение.
+func privateTempDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	if runtime.GOOS == "linux" {
+		if stat, err := os.Stat("/dev/shm"); err == nil && stat.IsDir() {
+			return "/dev/shm"
+		}
+	}
+	warnf("the temporary file is stored on disk, set $XDG_RUNTIME_DIR to keep it in memory")
+	return os.TempDir()
+}
+
+// shred перезаписывает нулями все файлы директории dir и удаляет её.
+func shred(dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		return overwrite(path)
+	})
+	if rmErr := os.RemoveAll(dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// overwrite перезаписывает файл path нулями.
+func overwrite(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	zeros := make([]byte, 32<<10)
+	for n := stat.Size(); n > 0; {
+		m := int64(len(zeros))
+		if n < m {
+			m = n
+		}
+		if _, err = f.Write(zeros[:m]); err != nil {
+			return err
+		}
+		n -= m
+	}
+
+	return f.Sync()
+}
@@ -0,0 +1,45 @@
+package cliutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the editor is a shell script")
+	}
+
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", `sed -i.bak "s/old/new/"`)
+
+	buf, err := Edit("note.txt", []byte("old secret\n"))
+	require.NoError(t, err)
+	require.Equal(t, "new secret\n", string(buf.Bytes()))
+
+	// Временная директория удалена вместе с резервной копией редактора.
+	entries, err := os.ReadDir(runtimeDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestShred(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(path, []byte("secret"), 0o600))
+
+	require.NoError(t, overwrite(path))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, 6), b)
+
+	require.NoError(t, shred(dir))
+	require.NoDirExists(t, dir)
+}
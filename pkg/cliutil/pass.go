@@ -1,21 +1,69 @@
 package cliutil
 
 import (
+	"errors"
 	"fmt"
 	"syscall"
 
 	"golang.org/x/term"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
 )
 
-// ReadPassword считывает пароль из терминала и возвращает его.
-func ReadPassword() (string, error) {
-	fmt.Print("Password:")
+// ReadPassword считывает пароль из терминала и возвращает его. Если
+// установлен источник пароля, то пароль считывается из него. Буфер пароля
+// принадлежит вызывающей стороне.
+func ReadPassword() (*secmem.Buffer, error) {
+	if pass, ok, err := sourcePassword(); ok {
+		return pass, err
+	}
+	return readPassword("Password:")
+}
+
+// ReadNewPassword дважды считывает новый пароль из терминала и возвращает
+// его, если оба значения совпадают. Если установлен источник пароля, из
+// которого пароль ещё не считывался, то пароль считывается из него без
+// подтверждения; иначе новый пароль заменяет уже считанный и запрашивается
+// из терминала. Буфер пароля принадлежит вызывающей стороне.
+func ReadNewPassword() (*secmem.Buffer, error) {
+	if !sourceUsed() {
+		if pass, ok, err := sourcePassword(); ok {
+			return pass, err
+		}
+	}
+
+	pass, err := readPassword("New password:")
+	if err != nil {
+		return nil, err
+	}
+	if pass.Len() == 0 {
+		return nil, errors.New("password must not be blank")
+	}
+
+	repeated, err := readPassword("Repeat password:")
+	if err != nil {
+		pass.Destroy()
+		return nil, err
+	}
+	defer repeated.Destroy()
+
+	if !pass.Equal(repeated) {
+		pass.Destroy()
+		return nil, errors.New("passwords do not match")
+	}
+
+	return pass, nil
+}
+
+func readPassword(prompt string) (*secmem.Buffer, error) {
+	fmt.Print(prompt)
 	defer fmt.Println()
 
 	pass, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer secmem.Wipe(pass)
 
-	return string(pass), nil
+	return secmem.Copy(pass), nil
 }
@@ -0,0 +1,204 @@
+package cliutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// maxPasswordSize определяет максимальный размер пароля, считываемого из
+// неинтерактивного источника.
+const maxPasswordSize = 4 << 10
+
+// PasswordSource определяет неинтерактивный источник пароля. Из источника
+// используется первая строка без символа перевода строки. Возвращаемый срез
+// очищается после использования.
+type PasswordSource func() ([]byte, error)
+
+var (
+	sourceMu   sync.Mutex
+	source     PasswordSource
+	sourcePass *secmem.Buffer
+)
+
+// SetPasswordSource устанавливает источник, из которого ReadPassword и
+// ReadNewPassword считывают пароль вместо терминала. Пароль считывается из
+// источника не более одного раза. Если src равен nil, то пароль снова
+// считывается из терминала.
+func SetPasswordSource(src PasswordSource) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+
+	source = src
+	sourcePass.Destroy()
+	sourcePass = nil
+}
+
+// sourcePassword возвращает копию пароля из установленного источника; ok
+// равен false, если источник не установлен.
+func sourcePassword() (pass *secmem.Buffer, ok bool, err error) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+
+	if source == nil {
+		return nil, false, nil
+	}
+	if sourcePass != nil {
+		return sourcePass.Clone(), true, nil
+	}
+
+	b, err := source()
+	defer secmem.Wipe(b)
+
+	if err != nil {
+		return nil, true, err
+	}
+	if len(b) == 0 {
+		return nil, true, errors.New("password must not be blank")
+	}
+
+	sourcePass = secmem.Copy(b)
+
+	return sourcePass.Clone(), true, nil
+}
+
+// sourceUsed возвращает true, если пароль уже считан из источника.
+func sourceUsed() bool {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	return sourcePass != nil
+}
+
+// PasswordFD возвращает источник, считывающий пароль из открытого файлового
+// дескриптора fd.
+func PasswordFD(fd uintptr) PasswordSource {
+	return func() ([]byte, error) {
+		f := os.NewFile(fd, fmt.Sprintf("fd%d", fd))
+		if f == nil {
+			return nil, fmt.Errorf("file descriptor %d is invalid", fd)
+		}
+		if fd > 2 {
+			defer f.Close()
+		}
+
+		pass, err := readLine(f)
+		if err != nil {
+			return nil, fmt.Errorf("read password from file descriptor %d: %w", fd, err)
+		}
+
+		return pass, nil
+	}
+}
+
+// PasswordFile возвращает источник, считывающий пароль из файла path. Если
+// файл доступен другим пользователям, то выводится предупреждение.
+func PasswordFile(path string) PasswordSource {
+	return func() ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if runtime.GOOS != "windows" && stat.Mode().Perm()&0o077 != 0 {
+			warnf("password file %s is accessible by other users", path)
+		}
+
+		pass, err := readLine(f)
+		if err != nil {
+			return nil, fmt.Errorf("read password from %s: %w", path, err)
+		}
+
+		return pass, nil
+	}
+}
+
+// PasswordCommand возвращает источник, который выполняет команду command
+// в командной оболочке и считывает пароль из её стандартного вывода.
+// Стандартные ввод и вывод ошибок команды связываются с текущим процессом.
+func PasswordCommand(command string) PasswordSource {
+	return func() ([]byte, error) {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("password command: %w", err)
+		}
+
+		defer secmem.Wipe(out)
+
+		pass, err := readLine(bytes.NewReader(out))
+		if err != nil {
+			return nil, fmt.Errorf("password command: %w", err)
+		}
+
+		return pass, nil
+	}
+}
+
+// PasswordEnv возвращает источник, считывающий пароль из переменной
+// окружения name. Значение переменной считывается сразу, а сама она
+// удаляется из окружения процесса, чтобы пароль не передавался дочерним
+// процессам. Окружение доступно другим процессам пользователя, поэтому
+// выводится предупреждение.
+func PasswordEnv(name string) PasswordSource {
+	value := []byte(os.Getenv(name))
+	_ = os.Unsetenv(name)
+
+	return func() ([]byte, error) {
+		warnf("reading the password from $%s is insecure, prefer a password file or command", name)
+		return firstLine(value), nil
+	}
+}
+
+// readLine считывает первую строку из r. Данные считываются побайтно, чтобы
+// не оставлять копий пароля в промежуточных буферах и не ожидать данных
+// после перевода строки.
+func readLine(r io.Reader) ([]byte, error) {
+	buf := secmem.New(maxPasswordSize)
+	defer buf.Destroy()
+
+	b := buf.Bytes()
+	for n := 0; n < len(b); {
+		m, err := r.Read(b[n : n+1])
+		n += m
+		if (m > 0 && b[n-1] == '\n') || errors.Is(err, io.EOF) {
+			return bytes.Clone(firstLine(b[:n])), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("password is too long")
+}
+
+// firstLine возвращает первую строку b без символа перевода строки.
+func firstLine(b []byte) []byte {
+	b, _, _ = bytes.Cut(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r"))
+}
+
+// warnf выводит предупреждение в стандартный вывод ошибок.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
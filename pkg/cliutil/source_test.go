@@ -0,0 +1,79 @@
+package cliutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("secret\r\nignored\n"), 0o600))
+
+	t.Setenv("TEST_PASSWORD", "secret")
+
+	testCases := []struct {
+		name string
+		src  PasswordSource
+	}{
+		{"file", PasswordFile(path)},
+		{"env", PasswordEnv("TEST_PASSWORD")},
+	}
+
+	_, ok := os.LookupEnv("TEST_PASSWORD")
+	require.False(t, ok)
+	if runtime.GOOS != "windows" {
+		testCases = append(testCases, struct {
+			name string
+			src  PasswordSource
+		}{"command", PasswordCommand("printf 'secret\\n'")})
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pass, err := tc.src()
+			require.NoError(t, err)
+			require.Equal(t, "secret", string(pass))
+		})
+	}
+}
+
+func TestPasswordSource_Error(t *testing.T) {
+	_, err := PasswordFile(filepath.Join(t.TempDir(), "missing"))()
+	require.Error(t, err)
+
+	if runtime.GOOS != "windows" {
+		_, err = PasswordCommand("exit 1")()
+		require.Error(t, err)
+	}
+}
+
+func TestReadPassword_Source(t *testing.T) {
+	calls := 0
+	SetPasswordSource(func() ([]byte, error) {
+		calls++
+		return []byte("secret"), nil
+	})
+	t.Cleanup(func() { SetPasswordSource(nil) })
+
+	pass, err := ReadNewPassword()
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(pass.Bytes()))
+
+	// Каждый вызов возвращает собственную копию пароля.
+	pass.Destroy()
+
+	pass, err = ReadPassword()
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(pass.Bytes()))
+
+	require.Equal(t, 1, calls)
+
+	SetPasswordSource(func() ([]byte, error) { return nil, nil })
+
+	_, err = ReadPassword()
+	require.Error(t, err)
+}
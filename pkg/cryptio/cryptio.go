@@ -3,7 +3,6 @@ package cryptio
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -55,15 +54,16 @@ type Decrypter struct {
 	meta   Meta
 }
 
-// NewEncrypter возвращает новый экземпляр Encrypter.
-func NewEncrypter(src io.Reader, key string) (*Encrypter, error) {
-	block, err := aes.NewCipher(hash32(key))
+// NewEncrypter возвращает новый экземпляр Encrypter. Размер ключа должен
+// составлять 16, 24 или 32 байта.
+func NewEncrypter(src io.Reader, key []byte) (*Encrypter, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 
 	iv := make([]byte, block.BlockSize())
-	if _, err = randutil.Rand.Read(iv); err != nil {
+	if err = randutil.Read(iv); err != nil {
 		return nil, err
 	}
 
@@ -77,9 +77,10 @@ func NewEncrypter(src io.Reader, key string) (*Encrypter, error) {
 	}, nil
 }
 
-// NewDecrypter возвращает новый экземпляр Decrypter.
-func NewDecrypter(src io.Reader, key string, meta Meta) (*Decrypter, error) {
-	block, err := aes.NewCipher(hash32(key))
+// NewDecrypter возвращает новый экземпляр Decrypter. Размер ключа должен
+// составлять 16, 24 или 32 байта.
+func NewDecrypter(src io.Reader, key []byte, meta Meta) (*Decrypter, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -121,9 +122,3 @@ func (dec *Decrypter) Read(p []byte) (n int, err error) {
 	dec.stream.XORKeyStream(p[:n], p[:n])
 	return n, nil
 }
-
-func hash32(s string) []byte {
-	hash := md5.New()
-	hash.Write([]byte(s))
-	return hash.Sum(nil)
-}
@@ -3,8 +3,10 @@ package cryptio_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/require"
 
@@ -13,7 +15,7 @@ import (
 )
 
 func Test_EncryptDecrypt(t *testing.T) {
-	key := randutil.String(64)
+	key := randutil.Bytes(cryptio.KeySize)
 	text := randutil.Bytes(256)
 
 	enc, err := cryptio.NewEncrypter(bytes.NewReader(text), key)
@@ -35,7 +37,7 @@ func TestMeta(t *testing.T) {
 		Meta cryptio.Meta `json:"meta"`
 	}
 
-	key := randutil.String(64)
+	key := randutil.Bytes(cryptio.KeySize)
 	text := randutil.Bytes(256)
 
 	enc, err := cryptio.NewEncrypter(bytes.NewReader(text), key)
@@ -54,3 +56,206 @@ func TestMeta(t *testing.T) {
 
 	require.Equal(t, want, got)
 }
+
+func TestKDF(t *testing.T) {
+	password := randutil.Bytes(16)
+
+	kdf, err := cryptio.NewKDF(1, 64, 1)
+	require.NoError(t, err)
+
+	key := kdf.Key(password)
+	require.Len(t, key, cryptio.KeySize)
+	require.Equal(t, key, kdf.Key(password))
+	require.NotEqual(t, key, kdf.Key(append(password, 'x')))
+	require.Equal(t, cryptio.Checksum(key), cryptio.Checksum(kdf.Key(password)))
+	require.NotEqual(t, cryptio.Checksum(key), cryptio.Checksum(kdf.Key(append(password, 'x'))))
+
+	other, err := cryptio.NewKDF(1, 64, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, kdf.Salt, other.Salt)
+	require.NotEqual(t, key, other.Key(password))
+
+	require.Error(t, cryptio.KDF{}.Validate())
+}
+
+func seal(t *testing.T, key, text []byte) []byte {
+	s, err := cryptio.NewSealer(bytes.NewReader(text), key)
+	require.NoError(t, err)
+
+	sealed, err := io.ReadAll(s)
+	require.NoError(t, err)
+
+	return sealed
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	o, err := cryptio.NewOpener(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(o)
+}
+
+func Test_SealOpen(t *testing.T) {
+	key := randutil.Bytes(cryptio.KeySize)
+
+	sizes := []int{0, 1, cryptio.ChunkSize - 1, cryptio.ChunkSize, cryptio.ChunkSize + 1, 3 * cryptio.ChunkSize}
+
+	for _, size := range sizes {
+		text := randutil.Bytes(size)
+
+		got, err := open(key, seal(t, key, text))
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(text, got))
+	}
+}
+
+func Test_SealOpen_Tampered(t *testing.T) {
+	key := randutil.Bytes(cryptio.KeySize)
+	text := randutil.Bytes(2*cryptio.ChunkSize + 100)
+	sealed := seal(t, key, text)
+
+	header := len(sealed) - len(text) - 3*16
+	chunk := cryptio.ChunkSize + 16
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)/2] ^= 1
+	_, err := open(key, flipped)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	truncated := sealed[:header+2*chunk]
+	_, err = open(key, truncated)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	reordered := bytes.Clone(sealed)
+	copy(reordered[header:], sealed[header+chunk:header+2*chunk])
+	copy(reordered[header+chunk:], sealed[header:header+chunk])
+	_, err = open(key, reordered)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	_, err = open(randutil.Bytes(cryptio.KeySize), sealed)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	_, err = open(key, []byte("garbage"))
+	require.ErrorIs(t, err, cryptio.ErrFormat)
+}
+
+func Test_WrapUnwrap(t *testing.T) {
+	kek, err := cryptio.NewKey()
+	require.NoError(t, err)
+
+	key, err := cryptio.NewKey()
+	require.NoError(t, err)
+
+	wrapped, err := cryptio.Wrap(kek, key)
+	require.NoError(t, err)
+	require.Len(t, wrapped, cryptio.WrappedSize)
+
+	got, err := cryptio.Unwrap(kek, wrapped)
+	require.NoError(t, err)
+	require.Equal(t, key, got)
+
+	_, err = cryptio.Unwrap(randutil.Bytes(cryptio.KeySize), wrapped)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	wrapped[len(wrapped)-1] ^= 1
+	_, err = cryptio.Unwrap(kek, wrapped)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+}
+
+func Test_RecipientWrapUnwrap(t *testing.T) {
+	alice, err := cryptio.GenerateIdentity()
+	require.NoError(t, err)
+
+	bob, err := cryptio.GenerateIdentity()
+	require.NoError(t, err)
+
+	recipient, err := cryptio.ParseRecipient(alice.Recipient().String())
+	require.NoError(t, err)
+
+	identity, err := cryptio.ParseIdentity(alice.String())
+	require.NoError(t, err)
+	require.Equal(t, alice.Bytes(), identity.Bytes())
+
+	key, err := cryptio.NewKey()
+	require.NoError(t, err)
+
+	stanza, err := recipient.Wrap(key)
+	require.NoError(t, err)
+	require.Len(t, stanza, cryptio.StanzaSize)
+
+	got, err := identity.Unwrap(stanza)
+	require.NoError(t, err)
+	require.Equal(t, key, got)
+
+	_, err = bob.Unwrap(stanza)
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	_, err = cryptio.ParseRecipient(alice.String())
+	require.Error(t, err)
+}
+
+func Test_EncryptDecrypt_Sealed(t *testing.T) {
+	key, err := cryptio.NewKey()
+	require.NoError(t, err)
+
+	want := []byte("some data")
+
+	sealed, err := cryptio.Encrypt(key, want, []byte("id"))
+	require.NoError(t, err)
+
+	got, err := cryptio.Decrypt(key, sealed, []byte("id"))
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	_, err = cryptio.Decrypt(key, sealed, []byte("other"))
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+
+	_, err = cryptio.Decrypt(randutil.Bytes(cryptio.KeySize), sealed, []byte("id"))
+	require.ErrorIs(t, err, cryptio.ErrAuthentication)
+}
+
+func TestCompositeKey(t *testing.T) {
+	key := randutil.Bytes(cryptio.KeySize)
+
+	keyfile, err := cryptio.NewKeyFile()
+	require.NoError(t, err)
+	require.Len(t, keyfile, cryptio.KeyFileSize)
+
+	digest, err := cryptio.KeyFileDigest(bytes.NewReader(keyfile))
+	require.NoError(t, err)
+
+	other, err := cryptio.KeyFileDigest(bytes.NewReader(append(keyfile, 0)))
+	require.NoError(t, err)
+
+	composite := cryptio.CompositeKey(key, digest)
+	require.Len(t, composite, cryptio.KeySize)
+	require.Equal(t, composite, cryptio.CompositeKey(key, digest))
+	require.NotEqual(t, composite, cryptio.CompositeKey(key, other))
+	require.NotEqual(t, composite, key)
+}
+
+func Test_RandomSource(t *testing.T) {
+	defer func(r io.Reader) { randutil.Reader = r }(randutil.Reader)
+
+	key := randutil.Bytes(cryptio.KeySize)
+	errRandom := errors.New("random source")
+	randutil.Reader = iotest.ErrReader(errRandom)
+
+	_, err := cryptio.NewKey()
+	require.ErrorIs(t, err, errRandom)
+
+	_, err = cryptio.Encrypt(key, []byte("text"), nil)
+	require.ErrorIs(t, err, errRandom)
+
+	_, err = cryptio.NewSealer(bytes.NewReader([]byte("text")), key)
+	require.ErrorIs(t, err, errRandom)
+
+	randutil.Reader = bytes.NewReader(bytes.Repeat([]byte{1}, 2*cryptio.KeySize))
+
+	k1, err := cryptio.NewKey()
+	require.NoError(t, err)
+	k2, err := cryptio.NewKey()
+	require.NoError(t, err)
+	require.Equal(t, k1, k2)
+}
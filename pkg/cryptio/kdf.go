@@ -0,0 +1,127 @@
+package cryptio
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+)
+
+const (
+	KeySize     = 32 // Размер ключа шифрования.
+	SaltSize    = 16 // Размер соли.
+	KeyFileSize = 64 // Размер генерируемого ключевого файла.
+
+	DefaultTime    = 3         // Количество проходов Argon2id по умолчанию.
+	DefaultMemory  = 64 * 1024 // Объём памяти Argon2id по умолчанию, КиБ.
+	DefaultThreads = 4         // Степень параллелизма Argon2id по умолчанию.
+)
+
+// KDF определяет параметры формирования ключа шифрования из пароля по
+// алгоритму Argon2id.
+type KDF struct {
+	Salt    []byte `json:"salt"`    // Случайная соль.
+	Time    uint32 `json:"time"`    // Количество проходов.
+	Memory  uint32 `json:"memory"`  // Объём памяти, КиБ.
+	Threads uint8  `json:"threads"` // Степень параллелизма.
+}
+
+// NewKDF возвращает параметры формирования ключа со случайной солью.
+// Нулевые значения параметров заменяются значениями по умолчанию.
+func NewKDF(iterations, memory uint32, threads uint8) (KDF, error) {
+	if iterations == 0 {
+		iterations = DefaultTime
+	}
+	if memory == 0 {
+		memory = DefaultMemory
+	}
+	if threads == 0 {
+		threads = DefaultThreads
+	}
+
+	salt := make([]byte, SaltSize)
+	if err := randutil.Read(salt); err != nil {
+		return KDF{}, err
+	}
+
+	kdf := KDF{
+		Salt:    salt,
+		Time:    iterations,
+		Memory:  memory,
+		Threads: threads,
+	}
+
+	return kdf, kdf.Validate()
+}
+
+// Validate возвращает ошибку, если параметры формирования ключа не валидны.
+func (kdf KDF) Validate() error {
+	if len(kdf.Salt) < SaltSize {
+		return errors.New("kdf salt is too short")
+	}
+	if kdf.Time == 0 {
+		return errors.New("kdf time must be positive")
+	}
+	if kdf.Memory < 8*uint32(kdf.Threads) {
+		return errors.New("kdf memory is too small")
+	}
+	if kdf.Threads == 0 {
+		return errors.New("kdf threads must be positive")
+	}
+	return nil
+}
+
+// Key формирует ключ шифрования из пароля. Ключ следует очистить после
+// использования.
+func (kdf KDF) Key(password []byte) []byte {
+	return argon2.IDKey(password, kdf.Salt, kdf.Time, kdf.Memory, kdf.Threads, KeySize)
+}
+
+// NewKeyFile генерирует содержимое ключевого файла.
+func NewKeyFile() ([]byte, error) {
+	b := make([]byte, KeyFileSize)
+	if err := randutil.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// KeyFileDigest считывает содержимое ключевого файла из r и возвращает его
+// хеш. Ключевым файлом может служить файл с любым содержимым.
+func KeyFileDigest(r io.Reader) ([]byte, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// CompositeKey объединяет ключ, сформированный из пароля, с хешем ключевого
+// файла, так что результат нельзя получить без любого из них.
+func CompositeKey(key, digest []byte) []byte {
+	composite := make([]byte, KeySize)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, key, digest, []byte("gophkeeper key file")), composite)
+	return composite
+}
+
+// LegacyKey формирует ключ шифрования из пароля устаревшим способом (MD5 без
+// соли). Используется только для расшифровки старых данных.
+func LegacyKey(password []byte) []byte {
+	hash := md5.New()
+	hash.Write(password)
+	return hash.Sum(nil)
+}
+
+// Checksum возвращает контрольное значение ключа шифрования, по которому
+// можно проверить правильность пароля, не расшифровывая данные.
+func Checksum(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("gophkeeper key check"))
+	return mac.Sum(nil)
+}
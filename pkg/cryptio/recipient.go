@@ -0,0 +1,176 @@
+package cryptio
+
+import (
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+)
+
+// Формат ключа, обёрнутого для получателя:
+//
+//	stanza = ephemeral(32) | wrapped
+//
+// Ключ обёртывания формируется по HKDF-SHA256 из общего секрета X25519
+// эфемерного ключа отправителя и открытого ключа получателя; солью служат
+// оба открытых ключа. Сам ключ обёртывается так же, как в Wrap.
+
+const (
+	x25519Size = 32
+	x25519Info = "gophkeeper x25519"
+
+	recipientPrefix = "gk-pub-"
+	identityPrefix  = "GK-SECRET-KEY-"
+
+	// StanzaSize определяет размер ключа, обёрнутого для получателя.
+	StanzaSize = x25519Size + WrappedSize
+)
+
+var keyEncoding = base64.RawURLEncoding
+
+// Recipient определяет открытый ключ X25519 получателя.
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// ParseRecipient разбирает открытый ключ, полученный из Recipient.String.
+func ParseRecipient(s string) (*Recipient, error) {
+	b, err := decodeKey(s, recipientPrefix)
+	if err != nil {
+		return nil, errors.New("recipient is invalid")
+	}
+	key, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, errors.New("recipient is invalid")
+	}
+	return &Recipient{key: key}, nil
+}
+
+// String возвращает текстовое представление открытого ключа.
+func (r *Recipient) String() string {
+	return recipientPrefix + keyEncoding.EncodeToString(r.key.Bytes())
+}
+
+// Wrap шифрует ключ key для получателя и возвращает результат.
+func (r *Recipient) Wrap(key []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(randutil.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := stanzaKey(shared, ephemeral.PublicKey(), r.key)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := Wrap(kek, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ephemeral.PublicKey().Bytes(), wrapped...), nil
+}
+
+// Identity определяет закрытый ключ X25519 получателя.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity генерирует новый закрытый ключ.
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(randutil.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity разбирает закрытый ключ, полученный из Identity.String.
+func ParseIdentity(s string) (*Identity, error) {
+	b, err := decodeKey(s, identityPrefix)
+	if err != nil {
+		return nil, errors.New("identity is invalid")
+	}
+	return NewIdentity(b)
+}
+
+// NewIdentity возвращает закрытый ключ по его байтовому представлению.
+func NewIdentity(b []byte) (*Identity, error) {
+	key, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return nil, errors.New("identity is invalid")
+	}
+	return &Identity{key: key}, nil
+}
+
+// Bytes возвращает байтовое представление закрытого ключа.
+func (id *Identity) Bytes() []byte {
+	return id.key.Bytes()
+}
+
+// String возвращает текстовое представление закрытого ключа.
+func (id *Identity) String() string {
+	return identityPrefix + keyEncoding.EncodeToString(id.key.Bytes())
+}
+
+// Recipient возвращает открытый ключ получателя.
+func (id *Identity) Recipient() *Recipient {
+	return &Recipient{key: id.key.PublicKey()}
+}
+
+// Unwrap расшифровывает ключ, зашифрованный для получателя. Если ключ
+// зашифрован для другого получателя или повреждён, то возвращается
+// ErrAuthentication.
+func (id *Identity) Unwrap(stanza []byte) ([]byte, error) {
+	if len(stanza) != StanzaSize {
+		return nil, ErrFormat
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:x25519Size])
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	shared, err := id.key.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	kek, err := stanzaKey(shared, ephemeral, id.key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return Unwrap(kek, stanza[x25519Size:])
+}
+
+// stanzaKey формирует ключ обёртывания из общего секрета shared.
+func stanzaKey(shared []byte, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+
+	kek := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), kek); err != nil {
+		return nil, err
+	}
+
+	return kek, nil
+}
+
+func decodeKey(s, prefix string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, prefix) {
+		return nil, ErrFormat
+	}
+	return keyEncoding.DecodeString(s[len(prefix):])
+}
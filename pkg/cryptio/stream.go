@@ -0,0 +1,266 @@
+package cryptio
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+// Формат потока с аутентификацией:
+//
+//	header = magic(4) | version(1) | salt(32)
+//	stream = header | chunk_0 | ... | chunk_n
+//
+// Каждый фрагмент содержит не более ChunkSize байт открытого текста,
+// зашифрованного AES-256-GCM ключом, полученным из ключа шифрования и соли
+// по HKDF-SHA256. Одноразовый номер фрагмента состоит из его порядкового
+// номера и флага последнего фрагмента, а заголовок аутентифицируется как
+// дополнительные данные каждого фрагмента. Благодаря этому обнаруживаются
+// изменение, перестановка и усечение фрагментов.
+
+const (
+	ChunkSize = 64 << 10 // Размер фрагмента открытого текста.
+
+	streamVersion = 1
+	streamInfo    = "gophkeeper stream"
+
+	magicSize      = 4
+	streamSaltSize = 32
+	headerSize     = magicSize + 1 + streamSaltSize
+	nonceSize      = 12
+	tagSize        = 16
+)
+
+var streamMagic = []byte("gkae")
+
+var (
+	// ErrFormat возвращается, когда формат потока не поддерживается.
+	ErrFormat = errors.New("stream format is not supported")
+
+	// ErrAuthentication возвращается, когда поток повреждён или изменён.
+	ErrAuthentication = errors.New("stream is corrupted or tampered")
+)
+
+// stream определяет общее состояние шифратора и дешифратора потока.
+type stream struct {
+	aead    cipher.AEAD
+	header  []byte
+	nonce   [nonceSize]byte
+	counter uint64
+}
+
+func newStream(key, header []byte) (*stream, error) {
+	salt := header[magicSize+1:]
+
+	subkey := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte(streamInfo)), subkey); err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(subkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stream{aead: aead, header: header}, nil
+}
+
+// next возвращает одноразовый номер очередного фрагмента.
+func (s *stream) next(last bool) []byte {
+	binary.BigEndian.PutUint64(s.nonce[3:11], s.counter)
+	s.nonce[11] = 0
+	if last {
+		s.nonce[11] = 1
+	}
+	s.counter++
+	return s.nonce[:]
+}
+
+// Sealer определяет потоковый шифратор данных с аутентификацией.
+type Sealer struct {
+	src    io.Reader
+	stream *stream
+	plain  []byte // Буфер открытого текста с упреждающим байтом.
+	n      int    // Количество байт в plain.
+	buf    []byte // Буфер зашифрованного фрагмента.
+	out    []byte // Зашифрованные данные, ожидающие чтения.
+	done   bool
+}
+
+// NewSealer возвращает новый экземпляр Sealer. Размер ключа должен
+// составлять 32 байта.
+func NewSealer(src io.Reader, key []byte) (*Sealer, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("key size is invalid")
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, streamMagic)
+	header[magicSize] = streamVersion
+
+	if err := randutil.Read(header[magicSize+1:]); err != nil {
+		return nil, err
+	}
+
+	s, err := newStream(key, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{
+		src:    src,
+		stream: s,
+		plain:  make([]byte, ChunkSize+1),
+		buf:    make([]byte, 0, ChunkSize+tagSize),
+		out:    header,
+	}, nil
+}
+
+// Read считывает байты из src, а затем шифрует их.
+func (s *Sealer) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// seal шифрует очередной фрагмент открытого текста.
+func (s *Sealer) seal() error {
+	n, err := io.ReadFull(s.src, s.plain[s.n:])
+	s.n += n
+
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	size := s.n
+	if !last {
+		size = ChunkSize
+	}
+
+	nonce := s.stream.next(last)
+	s.out = s.stream.aead.Seal(s.buf[:0], nonce, s.plain[:size], s.stream.header)
+
+	s.n = copy(s.plain, s.plain[size:s.n])
+	s.done = last
+
+	if last {
+		secmem.Wipe(s.plain)
+	}
+
+	return nil
+}
+
+// Opener определяет потоковый дешифратор данных с проверкой аутентичности.
+type Opener struct {
+	src    io.Reader
+	stream *stream
+	sealed []byte // Буфер зашифрованного фрагмента с упреждающим байтом.
+	n      int    // Количество байт в sealed.
+	buf    []byte // Буфер открытого текста.
+	out    []byte // Расшифрованные данные, ожидающие чтения.
+	done   bool
+}
+
+// NewOpener считывает заголовок потока из src и возвращает новый экземпляр
+// Opener. Размер ключа должен составлять 32 байта.
+func NewOpener(src io.Reader, key []byte) (*Opener, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("key size is invalid")
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrFormat
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:magicSize], streamMagic) || header[magicSize] != streamVersion {
+		return nil, ErrFormat
+	}
+
+	s, err := newStream(key, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Opener{
+		src:    src,
+		stream: s,
+		sealed: make([]byte, ChunkSize+tagSize+1),
+		buf:    make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+// Read считывает байты из src, а затем расшифровывает их.
+func (o *Opener) Read(p []byte) (int, error) {
+	for len(o.out) == 0 {
+		if o.done {
+			secmem.Wipe(o.buf[:cap(o.buf)])
+			return 0, io.EOF
+		}
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.out)
+	o.out = o.out[n:]
+	return n, nil
+}
+
+// open расшифровывает очередной фрагмент.
+func (o *Opener) open() error {
+	n, err := io.ReadFull(o.src, o.sealed[o.n:])
+	o.n += n
+
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	size := o.n
+	if !last {
+		size = ChunkSize + tagSize
+	}
+	if size < tagSize {
+		return ErrAuthentication
+	}
+
+	nonce := o.stream.next(last)
+
+	out, err := o.stream.aead.Open(o.buf[:0], nonce, o.sealed[:size], o.stream.header)
+	if err != nil {
+		return ErrAuthentication
+	}
+
+	o.out = out
+	o.n = copy(o.sealed, o.sealed[size:o.n])
+	o.done = last
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package cryptio
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+)
+
+// Формат зашифрованных данных и обёрнутого ключа:
+//
+//	sealed  = version(1) | nonce(12) | AES-256-GCM(plaintext)
+//	wrapped = version(1) | nonce(12) | AES-256-GCM(key)
+//
+// Версия вместе с дополнительными данными аутентифицируется.
+
+const (
+	wrapVersion = 1
+
+	// WrappedSize определяет размер обёрнутого ключа.
+	WrappedSize = 1 + nonceSize + KeySize + tagSize
+)
+
+// NewKey генерирует случайный ключ шифрования.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if err := randutil.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Wrap шифрует ключ key ключом kek и возвращает результат.
+func Wrap(kek, key []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("key size is invalid")
+	}
+	return Encrypt(kek, key, nil)
+}
+
+// Unwrap расшифровывает ключ, зашифрованный ключом kek. Если kek не
+// подходит или ключ повреждён, то возвращается ErrAuthentication.
+func Unwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) != WrappedSize {
+		return nil, ErrFormat
+	}
+	return Decrypt(kek, wrapped, nil)
+}
+
+// Encrypt шифрует небольшие данные plaintext ключом key и возвращает
+// результат. Дополнительные данные additional не шифруются, но
+// аутентифицируются.
+func Encrypt(key, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 1+nonceSize, 1+nonceSize+len(plaintext)+tagSize)
+	sealed[0] = wrapVersion
+
+	if err = randutil.Read(sealed[1:]); err != nil {
+		return nil, err
+	}
+
+	ad := append([]byte{wrapVersion}, additional...)
+
+	return aead.Seal(sealed, sealed[1:], plaintext, ad), nil
+}
+
+// Decrypt расшифровывает данные, зашифрованные Encrypt. Если ключ или
+// дополнительные данные не подходят либо данные повреждены, то возвращается
+// ErrAuthentication.
+func Decrypt(key, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < 1+nonceSize+tagSize || sealed[0] != wrapVersion {
+		return nil, ErrFormat
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := append([]byte{wrapVersion}, additional...)
+
+	plaintext, err := aead.Open(nil, sealed[1:1+nonceSize], sealed[1+nonceSize:], ad)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("key size is invalid")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
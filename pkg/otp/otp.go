@@ -0,0 +1,257 @@
+// Package otp реализует одноразовые пароли HOTP (RFC 4226) и TOTP
+// (RFC 6238) и разбор ключей в формате otpauth:// URI.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Виды одноразовых паролей.
+const (
+	TOTP = "totp" // Пароль, зависящий от времени.
+	HOTP = "hotp" // Пароль, зависящий от счётчика.
+)
+
+// Алгоритмы HMAC.
+const (
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+)
+
+// Значения параметров по умолчанию.
+const (
+	DefaultAlgorithm = SHA1
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key определяет ключ генерации одноразовых паролей.
+type Key struct {
+	Kind      string // Вид пароля: TOTP или HOTP.
+	Issuer    string // Сервис, выдавший ключ.
+	Account   string // Учётная запись.
+	Secret    []byte // Секрет.
+	Algorithm string // Алгоритм HMAC.
+	Digits    int    // Количество цифр пароля.
+	Period    int    // Период действия пароля TOTP в секундах.
+	Counter   uint64 // Счётчик HOTP.
+}
+
+// NewKey возвращает ключ вида kind с секретом secret в кодировке base32 и
+// параметрами по умолчанию.
+func NewKey(kind, secret string) (Key, error) {
+	b, err := DecodeSecret(secret)
+	if err != nil {
+		return Key{}, err
+	}
+
+	k := Key{
+		Kind:      kind,
+		Secret:    b,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+
+	return k, k.Validate()
+}
+
+// DecodeSecret декодирует секрет в кодировке base32. Регистр, пробелы,
+// дефисы и выравнивание не учитываются.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '=', '\t':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(secret))
+
+	b, err := encoding.DecodeString(secret)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("secret must be a base32 string")
+	}
+
+	return b, nil
+}
+
+// Parse разбирает ключ в формате otpauth://TYPE/LABEL?PARAMETERS.
+func Parse(uri string) (Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Key{}, err
+	}
+	if u.Scheme != "otpauth" {
+		return Key{}, errors.New("uri scheme must be otpauth")
+	}
+
+	q := u.Query()
+
+	k := Key{
+		Kind:      strings.ToLower(u.Host),
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		k.Issuer, k.Account = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		k.Account = label
+	}
+	if issuer := q.Get("issuer"); issuer != "" {
+		k.Issuer = issuer
+	}
+
+	if k.Secret, err = DecodeSecret(q.Get("secret")); err != nil {
+		return Key{}, err
+	}
+	if v := q.Get("algorithm"); v != "" {
+		k.Algorithm = strings.ToUpper(v)
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil {
+			return Key{}, fmt.Errorf("digits %q is invalid", v)
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		if k.Period, err = strconv.Atoi(v); err != nil {
+			return Key{}, fmt.Errorf("period %q is invalid", v)
+		}
+	}
+	if v := q.Get("counter"); v != "" {
+		if k.Counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return Key{}, fmt.Errorf("counter %q is invalid", v)
+		}
+	} else if k.Kind == HOTP {
+		return Key{}, errors.New("hotp uri must have a counter")
+	}
+
+	return k, k.Validate()
+}
+
+// URI возвращает ключ в формате otpauth:// URI.
+func (k Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	q := url.Values{}
+	q.Set("secret", encoding.EncodeToString(k.Secret))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", k.Algorithm)
+	q.Set("digits", strconv.Itoa(k.Digits))
+	if k.Kind == HOTP {
+		q.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		q.Set("period", strconv.Itoa(k.Period))
+	}
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     k.Kind,
+		Path:     "/" + label,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// Validate возвращает ошибку, если параметры ключа не поддерживаются.
+func (k Key) Validate() error {
+	if k.Kind != TOTP && k.Kind != HOTP {
+		return fmt.Errorf("otp type %q is not supported", k.Kind)
+	}
+	if len(k.Secret) == 0 {
+		return errors.New("secret must not be empty")
+	}
+	if _, err := newHash(k.Algorithm); err != nil {
+		return err
+	}
+	if k.Digits < 6 || k.Digits > 10 {
+		return errors.New("digits must be between 6 and 10")
+	}
+	if k.Kind == TOTP && k.Period <= 0 {
+		return errors.New("period must be positive")
+	}
+	return nil
+}
+
+// Generate вычисляет пароль HOTP для значения счётчика counter.
+func (k Key) Generate(counter uint64) (string, error) {
+	if err := k.Validate(); err != nil {
+		return "", err
+	}
+
+	h, _ := newHash(k.Algorithm)
+	mac := hmac.New(h, k.Secret)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Динамическое усечение (RFC 4226, раздел 5.3).
+	offset := sum[len(sum)-1] & 0x0f
+	code := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.Digits, code%mod), nil
+}
+
+// At вычисляет пароль TOTP в момент t и возвращает его вместе с оставшимся
+// временем действия.
+func (k Key) At(t time.Time) (string, time.Duration, error) {
+	if k.Kind != TOTP {
+		return "", 0, errors.New("key is not a totp key")
+	}
+	if err := k.Validate(); err != nil {
+		return "", 0, err
+	}
+
+	step := uint64(t.Unix()) / uint64(k.Period)
+	remaining := time.Unix(int64(step+1)*int64(k.Period), 0).Sub(t)
+
+	code, err := k.Generate(step)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return code, remaining, nil
+}
+
+func newHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("algorithm %q is not supported", algorithm)
+	}
+}
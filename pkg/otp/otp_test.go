@@ -0,0 +1,108 @@
+package otp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/otp"
+)
+
+// Тестовые векторы RFC 4226, приложение D.
+func TestKey_Generate(t *testing.T) {
+	key := otp.Key{
+		Kind:      otp.HOTP,
+		Secret:    []byte("12345678901234567890"),
+		Algorithm: otp.SHA1,
+		Digits:    6,
+	}
+
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, code := range want {
+		got, err := key.Generate(uint64(counter))
+		require.NoError(t, err)
+		require.Equal(t, code, got)
+	}
+}
+
+// Тестовые векторы RFC 6238, приложение B.
+func TestKey_At(t *testing.T) {
+	secrets := map[string]string{
+		otp.SHA1:   "12345678901234567890",
+		otp.SHA256: "12345678901234567890123456789012",
+		otp.SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	testCases := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, otp.SHA1, "94287082"},
+		{59, otp.SHA256, "46119246"},
+		{59, otp.SHA512, "90693936"},
+		{1111111109, otp.SHA1, "07081804"},
+		{1234567890, otp.SHA256, "91819424"},
+		{20000000000, otp.SHA512, "47863826"},
+	}
+
+	for _, tc := range testCases {
+		key := otp.Key{
+			Kind:      otp.TOTP,
+			Secret:    []byte(secrets[tc.algorithm]),
+			Algorithm: tc.algorithm,
+			Digits:    8,
+			Period:    30,
+		}
+
+		code, remaining, err := key.At(time.Unix(tc.unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, tc.want, code, "%s at %d", tc.algorithm, tc.unix)
+		require.Equal(t, time.Duration(30-tc.unix%30)*time.Second, remaining)
+	}
+}
+
+func TestParse(t *testing.T) {
+	key, err := otp.Parse("otpauth://totp/ACME%20Co:john@example.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60")
+	require.NoError(t, err)
+	require.Equal(t, otp.TOTP, key.Kind)
+	require.Equal(t, "ACME Co", key.Issuer)
+	require.Equal(t, "john@example.com", key.Account)
+	require.Equal(t, otp.SHA256, key.Algorithm)
+	require.Equal(t, 8, key.Digits)
+	require.Equal(t, 60, key.Period)
+
+	parsed, err := otp.Parse(key.URI())
+	require.NoError(t, err)
+	require.Equal(t, key, parsed)
+
+	hotp, err := otp.Parse("otpauth://hotp/alice?secret=jbswy3dpehpk3pxp&counter=7")
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), hotp.Counter)
+	require.Equal(t, otp.DefaultDigits, hotp.Digits)
+
+	for _, uri := range []string{
+		"https://totp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4",
+	} {
+		_, err = otp.Parse(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	key, err := otp.NewKey(otp.TOTP, "jbsw y3dp ehpk 3pxp")
+	require.NoError(t, err)
+	require.Equal(t, []byte("Hello!\xde\xad\xbe\xef"), key.Secret)
+
+	_, err = otp.NewKey(otp.TOTP, "not base32!")
+	require.Error(t, err)
+}
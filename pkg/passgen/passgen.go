@@ -0,0 +1,354 @@
+// Package passgen генерирует пароли и парольные фразы. Случайные числа
+// берутся из randutil, то есть из криптографически стойкого генератора
+// crypto/rand.
+package passgen
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+)
+
+// Классы символов пароля.
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!#$%&()*+,-./:;<=>?@[]^_{}~"
+)
+
+// Ambiguous содержит символы, которые легко спутать друг с другом.
+const Ambiguous = "0Oo1lI"
+
+// Ограничения параметров генерации.
+const (
+	MinLength = 8
+	MaxLength = 1024
+	MinWords  = 3
+	MaxWords  = 64
+)
+
+// Policy определяет требования к паролю. Пароль содержит хотя бы один
+// символ каждого включённого класса.
+type Policy struct {
+	Length           int  // Длина пароля.
+	Lower            bool // Строчные латинские буквы.
+	Upper            bool // Прописные латинские буквы.
+	Digits           bool // Цифры.
+	Symbols          bool // Специальные символы.
+	ExcludeAmbiguous bool // Исключить похожие символы.
+}
+
+// DefaultPolicy определяет требования к паролю по умолчанию.
+var DefaultPolicy = Policy{
+	Length:  20,
+	Lower:   true,
+	Upper:   true,
+	Digits:  true,
+	Symbols: true,
+}
+
+// classes возвращает алфавиты включённых классов символов.
+func (p Policy) classes() []string {
+	var classes []string
+	for _, class := range []struct {
+		on    bool
+		chars string
+	}{
+		{p.Lower, Lower},
+		{p.Upper, Upper},
+		{p.Digits, Digits},
+		{p.Symbols, Symbols},
+	} {
+		if !class.on {
+			continue
+		}
+		chars := class.chars
+		if p.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(Ambiguous, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+	return classes
+}
+
+// Validate возвращает ошибку, если по требованиям нельзя сгенерировать
+// пароль.
+func (p Policy) Validate() error {
+	if p.Length < MinLength || p.Length > MaxLength {
+		return fmt.Errorf("password length must be between %d and %d", MinLength, MaxLength)
+	}
+	if len(p.classes()) == 0 {
+		return errors.New("at least one character class must be enabled")
+	}
+	return nil
+}
+
+// Entropy возвращает оценку энтропии пароля в битах.
+func (p Policy) Entropy() float64 {
+	return float64(p.Length) * math.Log2(float64(len(strings.Join(p.classes(), ""))))
+}
+
+// Password генерирует пароль по требованиям p.
+func Password(p Policy) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	classes := p.classes()
+	alphabet := strings.Join(classes, "")
+
+	b := make([]byte, p.Length)
+	for i, class := range classes {
+		b[i] = class[randutil.Intn(len(class))]
+	}
+	for i := len(classes); i < len(b); i++ {
+		b[i] = alphabet[randutil.Intn(len(alphabet))]
+	}
+
+	// Перемешивание по Фишеру — Йетсу, чтобы обязательные символы классов
+	// не стояли в начале пароля.
+	for i := len(b) - 1; i > 0; i-- {
+		j := randutil.Intn(i + 1)
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return b, nil
+}
+
+// Estimate оценивает энтропию существующего пароля в битах. Каждый символ
+// даёт log2 от размера алфавита классов, встречающихся в пароле, а символ,
+// повторяющий предыдущий или продолжающий последовательность вроде abc или
+// 321, — только 1 бит. Слово из словаря, в том числе с прописными буквами и
+// заменами вроде @ вместо a, оценивается как выбор из словаря: распространённые
+// основы паролей дают около 5 бит, слова Wordlist — около 13 бит. Оценка
+// является верхней границей.
+func Estimate(password []byte) float64 {
+	var lower, upper, digits, symbols, other bool
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digits = true
+		case c >= 0x80:
+			other = true
+		default:
+			symbols = true
+		}
+	}
+
+	var pool int
+	for _, class := range []struct {
+		on   bool
+		size int
+	}{
+		{lower, len(Lower)},
+		{upper, len(Upper)},
+		{digits, len(Digits)},
+		{symbols, 33}, // Печатные символы ASCII, кроме букв и цифр.
+		{other, 128},  // Байты многобайтовых символов UTF-8.
+	} {
+		if class.on {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	bits := math.Log2(float64(pool))
+
+	norm := normalize(password)
+	defer clear(norm)
+
+	d := dictionary()
+
+	// sum[i] — наименьшая оценка первых i символов: либо оценка (i-1)-го
+	// символа, либо словарное слово, которое заканчивается на i-м символе.
+	sum := make([]float64, len(password)+1)
+	for i := 1; i <= len(password); i++ {
+		c := bits
+		if i > 1 {
+			if delta := int(password[i-1]) - int(password[i-2]); delta >= -1 && delta <= 1 {
+				c = 1
+			}
+		}
+		sum[i] = sum[i-1] + c
+
+		for j := max(0, i-d.maxLen); j <= i-minWordLength; j++ {
+			word, ok := d.words[string(norm[j:i])]
+			if !ok {
+				continue
+			}
+			if c := sum[j] + word + variations(password[j:i], norm[j:i]); c < sum[i] {
+				sum[i] = c
+			}
+		}
+	}
+
+	return sum[len(password)]
+}
+
+// minWordLength определяет минимальную длину словарного слова в пароле:
+// более короткие слова встречаются в случайных паролях.
+const minWordLength = 4
+
+// commonWords содержит распространённые основы паролей, которых нет в
+// Wordlist.
+var commonWords = []string{
+	"password", "passw", "qwerty", "qwertz", "azerty", "asdf", "zxcv", "qazwsx",
+	"letmein", "iloveyou", "admin", "welcome", "login", "secret", "changeme",
+	"monkey", "dragon", "master", "shadow", "sunshine", "princess", "football",
+	"baseball", "superman", "batman", "trustno", "hello", "abcd", "test",
+}
+
+// dict определяет словарь для оценки паролей: оценку каждого слова в битах
+// и длину самого длинного слова.
+type dict struct {
+	words  map[string]float64
+	maxLen int
+}
+
+// dictionary возвращает словарь из commonWords и Wordlist.
+var dictionary = sync.OnceValue(func() dict {
+	d := dict{words: make(map[string]float64, len(Wordlist())+len(commonWords))}
+	for _, list := range [][]string{commonWords, Wordlist()} {
+		bits := math.Log2(float64(len(list)))
+		for _, word := range list {
+			if len(word) < minWordLength {
+				continue
+			}
+			if old, ok := d.words[word]; !ok || bits < old {
+				d.words[word] = bits
+			}
+			d.maxLen = max(d.maxLen, len(word))
+		}
+	}
+	return d
+})
+
+// leet содержит замены символов, которыми обычно маскируют буквы слов.
+var leet = map[byte]byte{
+	'@': 'a', '4': 'a', '3': 'e', '1': 'i', '!': 'i',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't',
+}
+
+// normalize возвращает пароль в нижнем регистре без замен leet. Результат
+// следует очистить после использования.
+func normalize(password []byte) []byte {
+	norm := make([]byte, len(password))
+	for i, c := range password {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			c += 'a' - 'A'
+		case leet[c] != 0:
+			c = leet[c]
+		}
+		norm[i] = c
+	}
+	return norm
+}
+
+// variations возвращает оценку в битах того, как словарное слово norm
+// изменено в пароле: по биту за прописные буквы и за замены leet.
+func variations(word, norm []byte) float64 {
+	var upper, substituted bool
+	for i, c := range word {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c != norm[i]:
+			substituted = true
+		}
+	}
+
+	var bits float64
+	if upper {
+		bits++
+	}
+	if substituted {
+		bits++
+	}
+	return bits
+}
+
+// PassphrasePolicy определяет требования к парольной фразе.
+type PassphrasePolicy struct {
+	Words      int    // Количество слов.
+	Separator  string // Разделитель слов.
+	Capitalize bool   // Начинать слова с прописной буквы.
+}
+
+// DefaultPassphrasePolicy определяет требования к парольной фразе по
+// умолчанию.
+var DefaultPassphrasePolicy = PassphrasePolicy{
+	Words:     6,
+	Separator: "-",
+}
+
+// Validate возвращает ошибку, если по требованиям нельзя сгенерировать
+// парольную фразу.
+func (p PassphrasePolicy) Validate() error {
+	if p.Words < MinWords || p.Words > MaxWords {
+		return fmt.Errorf("passphrase must have %d to %d words", MinWords, MaxWords)
+	}
+	return nil
+}
+
+// Entropy возвращает энтропию парольной фразы в битах.
+func (p PassphrasePolicy) Entropy() float64 {
+	return float64(p.Words) * math.Log2(float64(len(Wordlist())))
+}
+
+// Passphrase генерирует парольную фразу из слов Wordlist по требованиям p.
+func Passphrase(p PassphrasePolicy) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	words := Wordlist()
+
+	// Слова списка EFF не длиннее 9 букв, поэтому фраза помещается в буфер
+	// без перевыделений, оставляющих копии в памяти.
+	b := make([]byte, 0, p.Words*(9+len(p.Separator)))
+	for i := 0; i < p.Words; i++ {
+		if i > 0 {
+			b = append(b, p.Separator...)
+		}
+		word := words[randutil.Intn(len(words))]
+		if p.Capitalize {
+			b = append(b, word[0]-'a'+'A')
+			word = word[1:]
+		}
+		b = append(b, word...)
+	}
+
+	return b, nil
+}
+
+// Большой список слов EFF для парольных фраз: 7776 слов, по одному на
+// каждый результат броска пяти игральных костей. Распространяется по
+// лицензии CC BY 3.0 US:
+//
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed eff_large_wordlist.txt
+var wordlist string
+
+// Wordlist возвращает список слов для парольных фраз.
+var Wordlist = sync.OnceValue(func() []string {
+	return strings.Fields(wordlist)
+})
@@ -0,0 +1,114 @@
+package passgen_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/passgen"
+)
+
+func TestPassword(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy passgen.Policy
+		valid  bool
+	}{
+		{"default", passgen.DefaultPolicy, true},
+		{"digits", passgen.Policy{Length: 8, Digits: true}, true},
+		{"unambiguous", passgen.Policy{Length: 64, Lower: true, Upper: true, Digits: true, ExcludeAmbiguous: true}, true},
+		{"too short", passgen.Policy{Length: 7, Lower: true}, false},
+		{"too long", passgen.Policy{Length: passgen.MaxLength + 1, Lower: true}, false},
+		{"no classes", passgen.Policy{Length: 20}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				b, err := passgen.Password(tc.policy)
+				if !tc.valid {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+				require.Len(t, b, tc.policy.Length)
+
+				s := string(b)
+				for _, class := range []struct {
+					on    bool
+					chars string
+				}{
+					{tc.policy.Lower, passgen.Lower},
+					{tc.policy.Upper, passgen.Upper},
+					{tc.policy.Digits, passgen.Digits},
+					{tc.policy.Symbols, passgen.Symbols},
+				} {
+					require.Equal(t, class.on, strings.ContainsAny(s, class.chars), s)
+				}
+				if tc.policy.ExcludeAmbiguous {
+					require.False(t, strings.ContainsAny(s, passgen.Ambiguous), s)
+				}
+			}
+		})
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	words := passgen.Wordlist()
+	require.Len(t, words, 7776)
+
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+
+	b, err := passgen.Passphrase(passgen.DefaultPassphrasePolicy)
+	require.NoError(t, err)
+
+	parts := strings.Split(string(b), "-")
+	require.Len(t, parts, 6)
+	for _, p := range parts {
+		require.True(t, set[p], p)
+	}
+
+	b, err = passgen.Passphrase(passgen.PassphrasePolicy{Words: 4, Separator: " ", Capitalize: true})
+	require.NoError(t, err)
+	for _, p := range strings.Split(string(b), " ") {
+		require.True(t, p[0] >= 'A' && p[0] <= 'Z', p)
+		require.True(t, set[strings.ToLower(p[:1])+p[1:]], p)
+	}
+
+	_, err = passgen.Passphrase(passgen.PassphrasePolicy{Words: 2})
+	require.Error(t, err)
+
+	require.InDelta(t, 77.5, passgen.DefaultPassphrasePolicy.Entropy(), 0.1)
+}
+
+func TestEstimate(t *testing.T) {
+	testCases := []struct {
+		password string
+		min, max float64
+	}{
+		{"", 0, 0},
+		{"aaaaaaaa", 5, 12},
+		{"abcdefgh", 5, 12},
+		{"password", 3, 8},
+		{"P@ssw0rd1", 10, 20},
+		{"Password1!", 15, 25},
+		{"dragon2019", 15, 30},
+		{"xkqzvbnm", 30, 38},
+		{"Q2%9gT{rM#fWy.p8EyOx", 120, 135},
+	}
+
+	for _, tc := range testCases {
+		got := passgen.Estimate([]byte(tc.password))
+		require.True(t, got >= tc.min && got <= tc.max, "%q: %.1f", tc.password, got)
+	}
+
+	for i := 0; i < 100; i++ {
+		b, err := passgen.Password(passgen.DefaultPolicy)
+		require.NoError(t, err)
+		require.Greater(t, passgen.Estimate(b), 80.0, string(b))
+	}
+}
@@ -0,0 +1,378 @@
+// Package pwned проверяет пароли по локальной копии базы Pwned Passwords
+// без обращения к сети.
+//
+// Поддерживаются текстовые файлы базы, отсортированные по хешу, со строками
+// вида HASH:COUNT, где HASH — SHA-1 или NTLM пароля в шестнадцатеричном виде,
+// а также компактный двоичный индекс, который строится из них BuildIndex:
+//
+//	index  = magic(8) | algorithm(1) | record*
+//	record = hash(20 для SHA-1, 16 для NTLM) | count(4)
+package pwned
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4" //nolint:staticcheck // NTLM определён через MD4.
+)
+
+// Алгоритмы хеширования паролей в базе.
+const (
+	SHA1 = "sha1"
+	NTLM = "ntlm"
+)
+
+var magic = []byte("GKPWNED1")
+
+// maxLine ограничивает длину строки текстовой базы.
+const maxLine = 256
+
+// hashSize возвращает размер хеша алгоритма algorithm.
+func hashSize(algorithm string) int {
+	switch algorithm {
+	case SHA1:
+		return sha1.Size
+	case NTLM:
+		return md4.Size
+	default:
+		return 0
+	}
+}
+
+// Hash возвращает хеш пароля по алгоритму algorithm.
+func Hash(algorithm string, password []byte) ([]byte, error) {
+	switch algorithm {
+	case SHA1:
+		sum := sha1.Sum(password)
+		return sum[:], nil
+	case NTLM:
+		// NTLM — это MD4 от пароля в кодировке UTF-16LE.
+		h := md4.New()
+		var buf [2]byte
+		for _, r := range utf16.Encode([]rune(string(password))) {
+			binary.LittleEndian.PutUint16(buf[:], r)
+			h.Write(buf[:])
+		}
+		return h.Sum(nil), nil
+	default:
+		return nil, fmt.Errorf("algorithm %q is not supported", algorithm)
+	}
+}
+
+// DB определяет локальную базу скомпрометированных паролей.
+type DB struct {
+	f         *os.File
+	size      int64
+	algorithm string
+	index     bool
+}
+
+// Open открывает текстовую базу или индекс по пути path. Алгоритм
+// определяется по индексу или по длине хеша в первой строке текстовой базы.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := open(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return db, nil
+}
+
+func open(f *os.File) (*DB, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{f: f, size: info.Size()}
+
+	head := make([]byte, maxLine)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	if bytes.HasPrefix(head, magic) {
+		if len(head) <= len(magic) {
+			return nil, errors.New("index is corrupted")
+		}
+		db.index = true
+		switch head[len(magic)] {
+		case 1:
+			db.algorithm = SHA1
+		case 2:
+			db.algorithm = NTLM
+		default:
+			return nil, fmt.Errorf("index algorithm %d is not supported", head[len(magic)])
+		}
+		if (db.size-db.offset())%int64(db.recordSize()) != 0 {
+			return nil, errors.New("index is corrupted")
+		}
+		return db, nil
+	}
+
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	hash, _, err := parseLine(line)
+	if err != nil {
+		return nil, err
+	}
+	switch len(hash) {
+	case sha1.Size:
+		db.algorithm = SHA1
+	case md4.Size:
+		db.algorithm = NTLM
+	default:
+		return nil, errors.New("hashes must be sha1 or ntlm")
+	}
+
+	return db, nil
+}
+
+// Algorithm возвращает алгоритм хеширования паролей в базе.
+func (db *DB) Algorithm() string {
+	return db.algorithm
+}
+
+// Close закрывает базу.
+func (db *DB) Close() error {
+	return db.f.Close()
+}
+
+// Count возвращает, сколько раз пароль встречался в утечках; 0 означает,
+// что пароля в базе нет.
+func (db *DB) Count(password []byte) (int, error) {
+	hash, err := Hash(db.algorithm, password)
+	if err != nil {
+		return 0, err
+	}
+	return db.Lookup(hash)
+}
+
+// Lookup возвращает, сколько раз хеш пароля встречался в утечках.
+func (db *DB) Lookup(hash []byte) (int, error) {
+	if len(hash) != hashSize(db.algorithm) {
+		return 0, fmt.Errorf("hash must have %d bytes", hashSize(db.algorithm))
+	}
+	if db.index {
+		return db.lookupIndex(hash)
+	}
+	return db.lookupText(hash)
+}
+
+func (db *DB) offset() int64 {
+	return int64(len(magic) + 1)
+}
+
+func (db *DB) recordSize() int {
+	return hashSize(db.algorithm) + 4
+}
+
+// lookupIndex выполняет двоичный поиск хеша по записям индекса.
+func (db *DB) lookupIndex(hash []byte) (int, error) {
+	size := db.recordSize()
+	n := (db.size - db.offset()) / int64(size)
+	record := make([]byte, size)
+
+	lo, hi := int64(0), n
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := db.f.ReadAt(record, db.offset()+mid*int64(size)); err != nil {
+			return 0, err
+		}
+		switch c := bytes.Compare(record[:len(hash)], hash); {
+		case c == 0:
+			return int(binary.BigEndian.Uint32(record[len(hash):])), nil
+		case c < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lookupText выполняет двоичный поиск хеша по смещениям в текстовой базе:
+// находит наименьшее смещение, с которого начинается строка с хешем не
+// меньше искомого, и сравнивает хеш этой строки с искомым.
+func (db *DB) lookupText(hash []byte) (int, error) {
+	lo, hi := int64(0), db.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := db.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == nil {
+			hi = mid
+			continue
+		}
+		h, _, err := parseLine(line)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Compare(h, hash) >= 0 {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	line, err := db.lineAt(lo)
+	if err != nil || line == nil {
+		return 0, err
+	}
+	h, count, err := parseLine(line)
+	if err != nil || !bytes.Equal(h, hash) {
+		return 0, err
+	}
+
+	return int(min(count, math.MaxUint32)), nil
+}
+
+// lineAt возвращает первую строку, которая начинается на смещении off или
+// после него, или nil, если такой строки нет.
+func (db *DB) lineAt(off int64) ([]byte, error) {
+	start := off
+	if off > 0 {
+		start-- // Строка начинается на off, если перед ним перевод строки.
+	}
+
+	buf := make([]byte, 2*maxLine)
+	n, err := db.f.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	if off > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			if int64(n) < int64(len(buf)) {
+				return nil, nil
+			}
+			return nil, errors.New("line is too long")
+		}
+		buf = buf[i+1:]
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	line, _, ok := bytes.Cut(buf, []byte("\n"))
+	if !ok && len(line) > maxLine {
+		return nil, errors.New("line is too long")
+	}
+
+	return line, nil
+}
+
+// parseLine разбирает строку текстовой базы вида HASH:COUNT.
+func parseLine(line []byte) (hash []byte, count uint64, err error) {
+	line = bytes.TrimRight(line, "\r")
+
+	h, c, ok := bytes.Cut(line, []byte(":"))
+	if !ok {
+		return nil, 0, fmt.Errorf("line %q must be HASH:COUNT", line)
+	}
+
+	hash = make([]byte, hex.DecodedLen(len(h)))
+	if _, err = hex.Decode(hash, h); err != nil {
+		return nil, 0, fmt.Errorf("line %q has an invalid hash", line)
+	}
+	if count, err = strconv.ParseUint(string(c), 10, 64); err != nil {
+		return nil, 0, fmt.Errorf("line %q has an invalid count", line)
+	}
+
+	return hash, count, nil
+}
+
+// BuildIndex строит из отсортированной текстовой базы src компактный
+// индекс, записывает его в dst и возвращает количество хешей.
+func BuildIndex(dst io.Writer, src io.Reader) (int, error) {
+	r := bufio.NewScanner(src)
+	r.Buffer(make([]byte, maxLine), maxLine)
+
+	w := bufio.NewWriter(dst)
+
+	var (
+		n         int
+		algorithm string
+		prev      []byte
+		record    []byte
+	)
+
+	for r.Scan() {
+		line := r.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		hash, count, err := parseLine(line)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		if algorithm == "" {
+			switch len(hash) {
+			case sha1.Size:
+				algorithm = SHA1
+			case md4.Size:
+				algorithm = NTLM
+			default:
+				return 0, errors.New("hashes must be sha1 or ntlm")
+			}
+			code := byte(1)
+			if algorithm == NTLM {
+				code = 2
+			}
+			if _, err = w.Write(append(bytes.Clone(magic), code)); err != nil {
+				return 0, err
+			}
+		}
+
+		if len(hash) != hashSize(algorithm) {
+			return 0, fmt.Errorf("line %d: hash must have %d bytes", n+1, hashSize(algorithm))
+		}
+		if prev != nil && bytes.Compare(prev, hash) >= 0 {
+			return 0, fmt.Errorf("line %d: hashes must be sorted and unique", n+1)
+		}
+		if count > math.MaxUint32 {
+			count = math.MaxUint32
+		}
+
+		record = append(record[:0], hash...)
+		record = binary.BigEndian.AppendUint32(record, uint32(count))
+		if _, err = w.Write(record); err != nil {
+			return 0, err
+		}
+
+		prev = hash
+		n++
+	}
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.New("database is empty")
+	}
+
+	return n, w.Flush()
+}
@@ -0,0 +1,123 @@
+package pwned
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	sha, err := Hash(SHA1, []byte("password"))
+	require.NoError(t, err)
+	require.Equal(t, "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", hex.EncodeToString(sha))
+
+	ntlm, err := Hash(NTLM, []byte("password"))
+	require.NoError(t, err)
+	require.Equal(t, "8846f7eaee8fb117ad06bdd830b7586c", hex.EncodeToString(ntlm))
+
+	_, err = Hash("md5", []byte("password"))
+	require.Error(t, err)
+}
+
+// writeDump записывает отсортированную текстовую базу с хешами паролей
+// passwords и возвращает путь к ней.
+func writeDump(t *testing.T, algorithm string, passwords map[string]int, crlf bool) string {
+	t.Helper()
+
+	var lines []string
+	for password, count := range passwords {
+		hash, err := Hash(algorithm, []byte(password))
+		require.NoError(t, err)
+		lines = append(lines, fmt.Sprintf("%X:%d", hash, count))
+	}
+	sort.Strings(lines)
+
+	sep := "\n"
+	if crlf {
+		sep = "\r\n"
+	}
+
+	path := filepath.Join(t.TempDir(), algorithm+".txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, sep)+sep), 0o600))
+
+	return path
+}
+
+func TestDB(t *testing.T) {
+	passwords := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		passwords[fmt.Sprintf("password%d", i)] = i + 1
+	}
+
+	for _, algorithm := range []string{SHA1, NTLM} {
+		for _, crlf := range []bool{false, true} {
+			dump := writeDump(t, algorithm, passwords, crlf)
+
+			index := filepath.Join(t.TempDir(), "index")
+			src, err := os.Open(dump)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			n, err := BuildIndex(&buf, src)
+			require.NoError(t, err)
+			require.NoError(t, src.Close())
+			require.Equal(t, len(passwords), n)
+			require.NoError(t, os.WriteFile(index, buf.Bytes(), 0o600))
+
+			for _, path := range []string{dump, index} {
+				db, err := Open(path)
+				require.NoError(t, err)
+				require.Equal(t, algorithm, db.Algorithm())
+
+				for password, want := range passwords {
+					got, err := db.Count([]byte(password))
+					require.NoError(t, err)
+					require.Equal(t, want, got, password)
+				}
+				for _, password := range []string{"", "password", "password500", "correct horse"} {
+					got, err := db.Count([]byte(password))
+					require.NoError(t, err)
+					require.Zero(t, got, password)
+				}
+
+				require.NoError(t, db.Close())
+			}
+		}
+	}
+}
+
+func TestBuildIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"empty", ""},
+		{"unsorted", "FF00000000000000000000000000000000000000:1\n0000000000000000000000000000000000000000:1\n"},
+		{"repeated", "0000000000000000000000000000000000000000:1\n0000000000000000000000000000000000000000:2\n"},
+		{"mixed", "0000000000000000000000000000000000000000:1\nFF000000000000000000000000000000:1\n"},
+		{"invalid hash", "XX00000000000000000000000000000000000000:1\n"},
+		{"no count", "0000000000000000000000000000000000000000\n"},
+		{"negative count", "0000000000000000000000000000000000000000:-1\n"},
+		{"unsupported", "0000:1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildIndex(&bytes.Buffer{}, strings.NewReader(tt.src))
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("saturated count", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := BuildIndex(&buf, strings.NewReader("0000000000000000000000000000000000000000:5000000000\n"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, buf.Bytes()[buf.Len()-4:])
+	})
+}
@@ -2,31 +2,59 @@ package randutil
 
 import (
 	"crypto/rand"
-	"encoding/binary"
+	"encoding/hex"
 	"io"
-	mathrand "math/rand"
 	"unsafe"
 )
 
-var Rand *mathrand.Rand
-
-func init() {
-	buf := make([]byte, 8)
-	_, err := io.ReadFull(rand.Reader, buf)
-	if err != nil {
-		panic(err)
-	}
-	src := mathrand.NewSource(int64(binary.LittleEndian.Uint64(buf)))
-	Rand = mathrand.New(src)
-}
+// Reader — источник случайных байтов пакета. По умолчанию это
+// криптографически стойкий генератор crypto/rand; тесты могут заменить его
+// детерминированным источником.
+var Reader io.Reader = rand.Reader
 
 const (
-	ascii = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFJHIJKLMNOPQRSTUVWXYZ"
-	hex   = "0123456789abcdef"
+	letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	ascii   = "0123456789" + letters
 )
 
+// Read заполняет b случайными байтами из Reader.
+func Read(b []byte) error {
+	_, err := io.ReadFull(Reader, b)
+	return err
+}
+
+// Intn возвращает равномерно распределённое случайное число из [0, n).
+// Вызывает панику, если n <= 0 или источник случайных байтов вернул ошибку.
+func Intn(n int) int {
+	if n <= 0 {
+		panic("randutil: invalid argument to Intn")
+	}
+	if n == 1 {
+		return 0
+	}
+
+	// Отбрасывание значений из неполного последнего диапазона исключает
+	// смещение распределения к меньшим числам.
+	bound := uint64(n)
+	limit := ^uint64(0) - ^uint64(0)%bound
+
+	var buf [8]byte
+	for {
+		mustRead(buf[:])
+
+		var x uint64
+		for _, b := range buf {
+			x = x<<8 | uint64(b)
+		}
+		if x < limit {
+			return int(x % bound)
+		}
+	}
+}
+
 // Bytes генерирует случайную байтовую последовательность длинной n, состоящую
-// из ASCII-символов.
+// из ASCII-символов. Первый символ всегда является буквой. Вызывает панику,
+// если источник случайных байтов вернул ошибку.
 //
 // Если n <= 0, то возвращает nil.
 func Bytes(n int) []byte {
@@ -34,17 +62,10 @@ func Bytes(n int) []byte {
 		return nil
 	}
 
-	buf := make([]byte, 0, n)
-	i := 0
-
-	for len(buf) < n {
-		idx := Rand.Intn(len(ascii) - 1)
-		char := ascii[idx]
-		if i == 0 && '0' <= char && char <= '9' {
-			continue
-		}
-		buf = append(buf, char)
-		i++
+	buf := make([]byte, n)
+	buf[0] = letters[Intn(len(letters))]
+	for i := 1; i < n; i++ {
+		buf[i] = ascii[Intn(len(ascii))]
 	}
 
 	return buf
@@ -58,7 +79,8 @@ func String(n int) string {
 	return unsafe.String(unsafe.SliceData(b), len(b))
 }
 
-// Hex генерирует случайную hex-последовательность длинной n.
+// Hex генерирует случайную hex-последовательность длинной n. Вызывает панику,
+// если источник случайных байтов вернул ошибку.
 //
 // Если n <= 0, то возвращает пустую строку.
 func Hex(n int) string {
@@ -66,18 +88,14 @@ func Hex(n int) string {
 		return ""
 	}
 
-	buf := make([]byte, 0, n)
-	i := 0
+	buf := make([]byte, (n+1)/2)
+	mustRead(buf)
 
-	for len(buf) < n {
-		idx := Rand.Intn(len(hex) - 1)
-		char := hex[idx]
-		if i == 0 && '0' <= char && char <= '9' {
-			continue
-		}
-		buf = append(buf, char)
-		i++
-	}
+	return hex.EncodeToString(buf)[:n]
+}
 
-	return string(buf)
+func mustRead(b []byte) {
+	if err := Read(b); err != nil {
+		panic("randutil: " + err.Error())
+	}
 }
@@ -1,7 +1,12 @@
 package randutil_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/require"
 
@@ -25,3 +30,42 @@ func TestString(t *testing.T) {
 		require.Len(t, s, tc.want)
 	}
 }
+
+func TestHex(t *testing.T) {
+	for _, n := range []int{0, -1, 1, 12, 33} {
+		s := randutil.Hex(n)
+		require.Len(t, s, max(n, 0))
+		require.Empty(t, strings.Trim(s, "0123456789abcdef"))
+	}
+
+	// Все символы алфавита должны встречаться.
+	s := randutil.Hex(4096)
+	for _, c := range "0123456789abcdef" {
+		require.Contains(t, s, string(c))
+	}
+}
+
+func TestIntn(t *testing.T) {
+	const n, samples = 10, 10000
+
+	var counts [n]int
+	for i := 0; i < samples; i++ {
+		counts[randutil.Intn(n)]++
+	}
+	for i, c := range counts {
+		require.InDelta(t, samples/n, c, samples/n/4, "value %d", i)
+	}
+
+	require.Panics(t, func() { randutil.Intn(0) })
+}
+
+func TestReader(t *testing.T) {
+	defer func(r io.Reader) { randutil.Reader = r }(randutil.Reader)
+
+	randutil.Reader = bytes.NewReader(bytes.Repeat([]byte{0xab}, 16))
+	require.Equal(t, "abababab", randutil.Hex(8))
+
+	randutil.Reader = iotest.ErrReader(errors.New("no entropy"))
+	require.Error(t, randutil.Read(make([]byte, 1)))
+	require.Panics(t, func() { randutil.Hex(8) })
+}
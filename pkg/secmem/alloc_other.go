@@ -0,0 +1,12 @@
+//go:build !unix
+
+package secmem
+
+// alloc выделяет память для n байт в куче Go: блокировка памяти на этой
+// платформе не поддерживается.
+func alloc(n int) ([]byte, bool) {
+	return make([]byte, n), false
+}
+
+// free освобождает память, выделенную alloc.
+func free([]byte, bool) {}
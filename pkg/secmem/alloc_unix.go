@@ -0,0 +1,39 @@
+//go:build unix
+
+package secmem
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc выделяет анонимную область памяти вне кучи Go, достаточную для n
+// байт, и блокирует её от выгрузки на диск. Если память не удалось
+// заблокировать, например, из-за ограничения RLIMIT_MEMLOCK, то она
+// используется незаблокированной.
+func alloc(n int) ([]byte, bool) {
+	size := pageAlign(n)
+
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size), false
+	}
+
+	excludeFromDump(mem)
+
+	return mem, unix.Mlock(mem) == nil
+}
+
+// free освобождает память, выделенную alloc.
+func free(mem []byte, locked bool) {
+	if locked {
+		_ = unix.Munlock(mem)
+	}
+	_ = unix.Munmap(mem)
+}
+
+func pageAlign(n int) int {
+	page := os.Getpagesize()
+	return (n + page - 1) / page * page
+}
@@ -0,0 +1,14 @@
+package secmem
+
+import "golang.org/x/sys/unix"
+
+// DisableCoreDumps запрещает создание дампов памяти процесса и его
+// подключение через ptrace пользователями без CAP_SYS_PTRACE.
+func DisableCoreDumps() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
+
+// excludeFromDump исключает mem из дампов памяти процесса.
+func excludeFromDump(mem []byte) {
+	_ = unix.Madvise(mem, unix.MADV_DONTDUMP)
+}
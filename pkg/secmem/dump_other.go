@@ -0,0 +1,13 @@
+//go:build !linux
+
+package secmem
+
+// DisableCoreDumps запрещает создание дампов памяти процесса. На этой
+// платформе не поддерживается и не имеет эффекта.
+func DisableCoreDumps() error {
+	return nil
+}
+
+// excludeFromDump исключает mem из дампов памяти процесса. На этой
+// платформе не поддерживается.
+func excludeFromDump([]byte) {}
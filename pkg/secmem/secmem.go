@@ -0,0 +1,167 @@
+// Package secmem предоставляет буферы для хранения секретов в памяти:
+// ключей шифрования, паролей и расшифрованных данных.
+//
+// Память буфера по возможности выделяется вне кучи Go и блокируется от
+// выгрузки на диск, а при освобождении заполняется нулями. Строки Go
+// неизменяемы и не могут быть очищены, поэтому секреты следует хранить
+// в буферах и срезах байт, а не в строках.
+package secmem
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Buffer определяет область памяти для хранения секрета. Нулевое значение
+// соответствует пустому буферу. Память не освобождается сборщиком мусора:
+// по окончании использования буфер нужно уничтожить при помощи Destroy.
+type Buffer struct {
+	mu     sync.Mutex
+	mem    []byte // Выделенная память, кратная размеру страницы.
+	n      int    // Размер секрета.
+	locked bool   // Память заблокирована от выгрузки на диск.
+}
+
+// New возвращает буфер размером n байт, заполненный нулями.
+func New(n int) *Buffer {
+	if n < 0 {
+		panic("secmem: negative buffer size")
+	}
+
+	b := &Buffer{n: n}
+	if n > 0 {
+		b.mem, b.locked = alloc(n)
+	}
+
+	return b
+}
+
+// Copy возвращает буфер с копией p. Исходный срез не изменяется; если он
+// больше не нужен, его следует очистить при помощи Wipe.
+func Copy(p []byte) *Buffer {
+	b := New(len(p))
+	copy(b.Bytes(), p)
+	return b
+}
+
+// Bytes возвращает содержимое буфера. Срез действителен до вызова Destroy.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.mem == nil {
+		return nil
+	}
+	return b.mem[:b.n:b.n]
+}
+
+// Len возвращает размер буфера.
+func (b *Buffer) Len() int {
+	return len(b.Bytes())
+}
+
+// Locked возвращает true, если память буфера заблокирована от выгрузки на
+// диск.
+func (b *Buffer) Locked() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.locked
+}
+
+// Equal сравнивает содержимое буферов за время, не зависящее от содержимого.
+func (b *Buffer) Equal(x *Buffer) bool {
+	return subtle.ConstantTimeCompare(b.Bytes(), x.Bytes()) == 1
+}
+
+// Clone возвращает копию буфера.
+func (b *Buffer) Clone() *Buffer {
+	return Copy(b.Bytes())
+}
+
+// Destroy заполняет буфер нулями и освобождает его память. Повторные вызовы
+// не имеют эффекта.
+func (b *Buffer) Destroy() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mem == nil {
+		return
+	}
+
+	Wipe(b.mem)
+	free(b.mem, b.locked)
+
+	b.mem, b.n, b.locked = nil, 0, false
+}
+
+// Wipe заполняет p нулями.
+func Wipe(p []byte) {
+	clear(p)
+	// Предотвращает удаление очистки как записи в неиспользуемую память.
+	runtime.KeepAlive(p)
+}
+
+// ReadAll считывает r до конца в буфер. Промежуточные копии данных
+// очищаются.
+func ReadAll(r io.Reader) (*Buffer, error) {
+	p := make([]byte, 0, 512)
+	defer func() { Wipe(p[:cap(p)]) }()
+
+	for {
+		if len(p) == cap(p) {
+			grown := append(p, 0)[:len(p)]
+			Wipe(p[:cap(p)])
+			p = grown
+		}
+
+		n, err := r.Read(p[len(p):cap(p)])
+		p = p[:len(p)+n]
+
+		if errors.Is(err, io.EOF) {
+			return Copy(p), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Reader определяет чтение содержимого буфера, который уничтожается при
+// закрытии.
+type Reader struct {
+	buf *Buffer
+	off int
+}
+
+// NewReader возвращает Reader, который читает содержимое buf и уничтожает
+// его при закрытии.
+func NewReader(buf *Buffer) *Reader {
+	return &Reader{buf: buf}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	b := r.buf.Bytes()
+	if r.off >= len(b) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[r.off:])
+	r.off += n
+	return n, nil
+}
+
+func (r *Reader) Close() error {
+	r.buf.Destroy()
+	return nil
+}
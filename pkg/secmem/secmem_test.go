@@ -0,0 +1,55 @@
+package secmem_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/secmem"
+)
+
+func TestBuffer(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	buf := secmem.Copy(secret)
+	require.Equal(t, secret, buf.Bytes())
+	require.Equal(t, len(secret), buf.Len())
+	require.True(t, buf.Equal(secmem.Copy(secret)))
+	require.False(t, buf.Equal(secmem.Copy([]byte("wrong"))))
+
+	clone := buf.Clone()
+	buf.Destroy()
+	buf.Destroy()
+
+	require.Nil(t, buf.Bytes())
+	require.Equal(t, secret, clone.Bytes())
+
+	secmem.Wipe(secret)
+	require.Equal(t, make([]byte, len(secret)), secret)
+}
+
+func TestBuffer_Empty(t *testing.T) {
+	var nilBuf *secmem.Buffer
+	require.Zero(t, nilBuf.Len())
+	require.True(t, nilBuf.Equal(secmem.New(0)))
+	nilBuf.Destroy()
+}
+
+func TestReadAll(t *testing.T) {
+	data := bytes.Repeat([]byte("secret"), 1000)
+
+	buf, err := secmem.ReadAll(iotest.OneByteReader(bytes.NewReader(data)))
+	require.NoError(t, err)
+	require.Equal(t, data, buf.Bytes())
+
+	r := secmem.NewReader(buf)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	require.NoError(t, r.Close())
+	require.Nil(t, buf.Bytes())
+}
@@ -0,0 +1,223 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Схема разделения секрета Шамира над полем GF(2^8): каждый байт секрета
+// является свободным членом случайного многочлена степени k-1, а доля i
+// содержит значения всех многочленов в точке x = i. Любые k долей
+// восстанавливают секрет интерполяцией Лагранжа в точке 0, меньшее их
+// количество не раскрывает о нём ничего.
+//
+// Текстовое представление доли:
+//
+//	share = set(4) | threshold(1) | x(1) | y | checksum(4)
+//
+// где set — случайный идентификатор разделения, а checksum — первые байты
+// SHA-256 остальных полей. Байты кодируются в base32 группами по 5 символов.
+
+const (
+	setSize      = 4
+	checksumSize = 4
+	groupSize    = 5
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Share определяет долю секрета.
+type Share struct {
+	Set       [setSize]byte // Идентификатор разделения.
+	Threshold byte          // Количество долей для восстановления.
+	X         byte          // Номер доли.
+	Y         []byte        // Значение доли.
+}
+
+// Split разделяет secret на n долей, любые k из которых восстанавливают его.
+func Split(secret []byte, n, k int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret must not be empty")
+	}
+	if k < 2 || k > n || n > 255 {
+		return nil, errors.New("parts must satisfy 2 <= k <= n <= 255")
+	}
+
+	var set [setSize]byte
+	if _, err := io.ReadFull(rand.Reader, set[:]); err != nil {
+		return nil, err
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{
+			Set:       set,
+			Threshold: byte(k),
+			X:         byte(i + 1),
+			Y:         make([]byte, len(secret)),
+		}
+	}
+
+	coeffs := make([]byte, k)
+	for j, b := range secret {
+		coeffs[0] = b
+		if _, err := io.ReadFull(rand.Reader, coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i].Y[j] = evaluate(coeffs, shares[i].X)
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine восстанавливает секрет из долей одного разделения.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+
+	first := shares[0]
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if share.Set != first.Set || share.Threshold != first.Threshold || len(share.Y) != len(first.Y) {
+			return nil, errors.New("shares belong to different splits")
+		}
+		if share.X == 0 || seen[share.X] {
+			return nil, fmt.Errorf("share %d is duplicated", share.X)
+		}
+		seen[share.X] = true
+	}
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("%d shares are required, %d given", first.Threshold, len(shares))
+	}
+
+	shares = shares[:first.Threshold]
+	secret := make([]byte, len(first.Y))
+
+	for i, si := range shares {
+		// Базисный многочлен Лагранжа в точке 0.
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = mul(basis, div(sj.X, sj.X^si.X))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= mul(si.Y[b], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+// String возвращает текстовое представление доли с контрольной суммой.
+func (s Share) String() string {
+	b := s.bytes()
+	sum := sha256.Sum256(b)
+	b = append(b, sum[:checksumSize]...)
+
+	text := encoding.EncodeToString(b)
+
+	var sb strings.Builder
+	for i := 0; i < len(text); i += groupSize {
+		if i > 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteString(text[i:min(i+groupSize, len(text))])
+	}
+
+	return sb.String()
+}
+
+// Parse разбирает текстовое представление доли и проверяет её контрольную
+// сумму.
+func Parse(text string) (Share, error) {
+	text = strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(text))
+
+	b, err := encoding.DecodeString(text)
+	if err != nil || len(b) < setSize+2+1+checksumSize {
+		return Share{}, errors.New("share is malformed")
+	}
+
+	data, checksum := b[:len(b)-checksumSize], b[len(b)-checksumSize:]
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:checksumSize], checksum) {
+		return Share{}, errors.New("share checksum mismatch, the share is mistyped")
+	}
+
+	var s Share
+	copy(s.Set[:], data)
+	s.Threshold = data[setSize]
+	s.X = data[setSize+1]
+	s.Y = data[setSize+2:]
+
+	return s, nil
+}
+
+func (s Share) bytes() []byte {
+	b := make([]byte, 0, setSize+2+len(s.Y)+checksumSize)
+	b = append(b, s.Set[:]...)
+	b = append(b, s.Threshold, s.X)
+	return append(b, s.Y...)
+}
+
+// evaluate вычисляет значение многочлена в точке x по схеме Горнера.
+func evaluate(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// Таблицы логарифмов и экспонент поля GF(2^8) с неприводимым многочленом
+// x^8 + x^4 + x^3 + x + 1 и образующим элементом 3.
+var (
+	expTable [510]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+		x ^= xtime(x)
+	}
+}
+
+// xtime умножает a на x в GF(2^8).
+func xtime(a byte) byte {
+	if a&0x80 != 0 {
+		return a<<1 ^ 0x1b
+	}
+	return a << 1
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
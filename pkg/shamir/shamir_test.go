@@ -0,0 +1,64 @@
+package shamir_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophkeeper/pkg/randutil"
+	"github.com/sergeizaitcev/gophkeeper/pkg/shamir"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := randutil.Bytes(48)
+
+	shares, err := shamir.Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	for i := 0; i < len(shares); i++ {
+		for j := i + 1; j < len(shares); j++ {
+			for k := j + 1; k < len(shares); k++ {
+				got, err := shamir.Combine([]shamir.Share{shares[k], shares[i], shares[j]})
+				require.NoError(t, err)
+				require.Equal(t, secret, got)
+			}
+		}
+	}
+
+	_, err = shamir.Combine(shares[:2])
+	require.Error(t, err)
+
+	_, err = shamir.Combine([]shamir.Share{shares[0], shares[0], shares[1]})
+	require.Error(t, err)
+
+	other, err := shamir.Split(secret, 5, 3)
+	require.NoError(t, err)
+
+	_, err = shamir.Combine([]shamir.Share{shares[0], shares[1], other[2]})
+	require.Error(t, err)
+
+	_, err = shamir.Split(secret, 2, 3)
+	require.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	shares, err := shamir.Split([]byte("secret"), 3, 2)
+	require.NoError(t, err)
+
+	text := shares[1].String()
+
+	got, err := shamir.Parse(" " + text + "\n")
+	require.NoError(t, err)
+	require.Equal(t, shares[1], got)
+
+	typo := []byte(text)
+	if typo[0] == 'A' {
+		typo[0] = 'B'
+	} else {
+		typo[0] = 'A'
+	}
+
+	_, err = shamir.Parse(string(typo))
+	require.Error(t, err)
+}
@@ -94,3 +94,8 @@ func (d Dir) Remove(name string) error {
 	path := filepath.Join(string(d), name)
 	return os.RemoveAll(path)
 }
+
+// Path возвращает полный путь к файлу или поддиректории.
+func (d Dir) Path(name string) string {
+	return filepath.Join(string(d), name)
+}